@@ -1,13 +1,44 @@
 package ast
 
-import "github.com/dywoq/minigo/pkg/token"
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dywoq/minigo/pkg/token"
+)
 
 // Node represents the node of the AST tree.
+// Pos and End return the position of the first character of the node,
+// and the position right after its last character.
 type Node interface {
+	Pos() token.Position
+	End() token.Position
 	node()
 }
 
-type Type string
+// Span is the range of the code a node is parsed from.
+// Every node embeds it; the nodes that aren't written in the code,
+// like the types inferred from the values, have the zero Span.
+type Span struct {
+	From token.Position `json:"from"`
+	To   token.Position `json:"to"`
+}
+
+// Pos returns the position of the first character of the node.
+func (s Span) Pos() token.Position {
+	return s.From
+}
+
+// End returns the position right after the last character of the node.
+func (s Span) End() token.Position {
+	return s.To
+}
+
+// IsExported reports whether the name starts with an upper-case letter.
+func IsExported(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(r)
+}
 
 // Value presentation in code:
 //
@@ -15,57 +46,266 @@ type Type string
 //  //       ^
 //  //       |
 //  //    The value
+//
+// Value holds the literal as it's written in the code,
+// so string values keep their quotes; use token.Unquote to get the actual string.
 type Value struct {
-	Value string `json:"value"`
+	Span
+	Value string     `json:"value"`
+	Kind  token.Kind `json:"kind"`
 }
 
 // Variable presentation in code:
 //
-//  var x = "Hi!"
-//  var y string = "Bye!"
-//
-// or:
-//
 //  z := "Goodbye!"
+//
+// Type is inferred from the value, and it's nil if the value alone doesn't tell it.
 type Variable struct {
-	Name     string `json:"name"`
-	Type     Type   `json:"type"`
-	Exported bool   `json:"exported"`
-	Value    Node   `json:"value"`
+	Span
+	Name     string   `json:"name"`
+	Type     TypeExpr `json:"type"`
+	Exported bool     `json:"exported"`
+	Value    Node     `json:"value"`
+}
+
+// Declaration presentation in code:
+//
+//  var x int
+//  var a, b = 1, 2
+//
+// or, when grouped:
+//
+//  const (
+//      A = iota
+//      B
+//  )
+//
+// Keyword is either "var" or "const".
+type Declaration struct {
+	Span
+	Keyword string      `json:"keyword"`
+	Grouped bool        `json:"grouped"`
+	Specs   []ValueSpec `json:"specs"`
+}
+
+// ValueSpec presentation in code:
+//
+//  var a, b int = 1, 2
+//  //  ^^^^^^^^^^^^^^^
+//  //         |
+//  //   The value spec
+//
+// Explicit reports whether the type was written in the code;
+// otherwise, Type is inferred from the values, and it's nil
+// if they're of different types, or the values alone don't tell it.
+//
+// Iota is the index of the spec within a const declaration.
+// A constant spec without values repeats the type and values of the previous one.
+type ValueSpec struct {
+	Span
+	Names    []string `json:"names"`
+	Type     TypeExpr `json:"type"`
+	Explicit bool     `json:"explicit"`
+	Values   []Node   `json:"values"`
+	Iota     int      `json:"iota"`
 }
 
 // Function presentation in code:
 //
-//  func greet(name string, additional ...string) {
+//  func greet(name string, additional ...string) (n int, err error) {
 //      // ...
 //  }
+//
+// or, when it's a method:
+//
+//  func (p *Point) Move(dx int) {
+//      // ...
+//  }
+//
+// or, when it's generic:
+//
+//  func Map[T, U any](xs []T, f func(T) U) []U {
+//      // ...
+//  }
+//
+// Receiver is nil for functions. Its Type is either a type name
+// or a pointer to the type name, and Names is empty if the receiver is unnamed.
+// TypeParams holds the type parameters with their constraints as the types.
 type Function struct {
-	Name       string             `json:"name"`
-	ReturnType string             `json:"return_type"`
-	Exported   bool               `json:"exported"`
-	Arguments  []FunctionArgument `json:"arguments"`
-	Body       []Node             `json:"body"`
+	Span
+	Name       string    `json:"name"`
+	Exported   bool      `json:"exported"`
+	Receiver   *Field    `json:"receiver"`
+	TypeParams FieldList `json:"type_params"`
+	Arguments  FieldList `json:"arguments"`
+	Results    FieldList `json:"results"`
+	Body       []Node    `json:"body"`
+}
+
+// Field presentation in code:
+//
+//  func greet(first, last string) {
+//      //     ^^^^^^^^^^^^^^^^^^
+//      //             |
+//      //         The field
+//  }
+//
+// Names is empty for unnamed fields, like unnamed results,
+// and for the embedded fields of structs.
+// Tag is the unquoted tag of the struct field, if any.
+type Field struct {
+	Span
+	Names    []string `json:"names"`
+	Type     TypeExpr `json:"type"`
+	Variadic bool     `json:"variadic"`
+	Tag      string   `json:"tag"`
 }
 
-// FunctionArgument presentation in code:
+// FieldList presentation in code:
 //
-//  func greet(name string) {
-//      //      ^
-//      //      |
-//      // The function argument
+//  func divide(a, b int) (int, error) {
+//      //     ^^^^^^^^^^ ^^^^^^^^^^^^
+//      //         |            |
+//      //     Arguments     Results
 //  }
-type FunctionArgument struct {
-	Identifier string     `json:"identifier"`
-	Type       token.Kind `json:"type"`
-	Variadic   bool       `json:"variadic"`
+type FieldList struct {
+	Span
+	Fields []Field `json:"fields"`
+}
+
+// Len returns the number of values described by the field list,
+// counting each name of the grouped fields separately.
+func (l FieldList) Len() int {
+	n := 0
+	for _, f := range l.Fields {
+		n += max(len(f.Names), 1)
+	}
+	return n
+}
+
+// TypeDeclaration presentation in code:
+//
+//  type Point struct {
+//      X, Y int
+//  }
+//
+// or, when it's an alias:
+//
+//  type Celsius = float
+//
+// or, when it's generic:
+//
+//  type Stack[T any] struct {
+//      items []T
+//  }
+type TypeDeclaration struct {
+	Span
+	Name       string    `json:"name"`
+	Exported   bool      `json:"exported"`
+	Alias      bool      `json:"alias"`
+	TypeParams FieldList `json:"type_params"`
+	Type       TypeExpr  `json:"type"`
+}
+
+// CompositeLiteral presentation in code:
+//
+//  p := Point{X: 1, Y: 2}
+//  xs := []Point{{1, 2}, {3, 4}}
+//  m := map[string]int{"a": 1}
+//
+// Type is nil if it's elided, like the type of "{1, 2}" in the second example.
+type CompositeLiteral struct {
+	Span
+	Type     TypeExpr `json:"type"`
+	Elements []Node   `json:"elements"`
+}
+
+// KeyValue presentation in code:
+//
+//  p := Point{X: 1}
+//  //         ^^^^
+//  //          |
+//  //    The key-value element
+type KeyValue struct {
+	Span
+	Key   Node `json:"key"`
+	Value Node `json:"value"`
 }
 
 // Call presentation in code:
 //
 //  print("Hi!", 10, 23)
+//  fmt.Println("Hi!")
+//
+// Function is the called expression, like the identifier "print"
+// or the selector "fmt.Println".
 type Call struct {
-	Identifier string         `json:"identifier"`
-	Arguments  []CallArgument `json:"arguments"`
+	Span
+	Function  Node           `json:"function"`
+	Arguments []CallArgument `json:"arguments"`
+}
+
+// SelectorExpr presentation in code:
+//
+//  p.X
+//  fmt.Println
+type SelectorExpr struct {
+	Span
+	Operand  Node   `json:"operand"`
+	Selector string `json:"selector"`
+}
+
+// IndexExpr presentation in code:
+//
+//  xs[i]
+//  m["key"]
+type IndexExpr struct {
+	Span
+	Operand Node `json:"operand"`
+	Index   Node `json:"index"`
+}
+
+// Instantiation presentation in code:
+//
+//  Map[int, string](xs, f)
+//  //^^^^^^^^^^^^^^
+//  //      |
+//  // The instantiation
+//
+// An instantiation with a single type argument that is also an expression,
+// like "Print[T]", is parsed as IndexExpr, since only the types tell them apart.
+type Instantiation struct {
+	Span
+	Operand       Node       `json:"operand"`
+	TypeArguments []TypeExpr `json:"type_arguments"`
+}
+
+// SliceExpr presentation in code:
+//
+//  s[1:3]
+//  s[1:3:5]
+//
+// Low, High and Max are nil if they're omitted, like in "s[:]".
+// Slice3 reports whether it's the 3-index slice, like the second example.
+type SliceExpr struct {
+	Span
+	Operand Node `json:"operand"`
+	Low     Node `json:"low"`
+	High    Node `json:"high"`
+	Max     Node `json:"max"`
+	Slice3  bool `json:"slice3"`
+}
+
+// TypeAssertExpr presentation in code:
+//
+//  n := v.(int)
+//
+// Type is nil if it's written as ".(type)", which is only allowed
+// in the guards of type switches.
+type TypeAssertExpr struct {
+	Span
+	Operand Node     `json:"operand"`
+	Type    TypeExpr `json:"type"`
 }
 
 // CallArgument presentation in code:
@@ -74,9 +314,12 @@ type Call struct {
 //  //     ^
 //  //     |
 //  //   The call argument
+//
+// Type is inferred from the value, and it's nil if the value alone doesn't tell it.
 type CallArgument struct {
-	Type  token.Kind `json:"type"`
-	Value Node       `json:"value"`
+	Span
+	Type  TypeExpr `json:"type"`
+	Value Node     `json:"value"`
 }
 
 // FunctionValue presentation in code:
@@ -84,18 +327,25 @@ type CallArgument struct {
 //  var greet = func(name string) {
 //     // ...
 //  }
+//
+// Captures lists the variables of the enclosing functions the function value refers to,
+// in the order of their first use. Like in Go, they're captured by reference.
 type FunctionValue struct {
-	ReturnType string             `json:"return_type"`
-	Arguments  []FunctionArgument `json:"arguments"`
-	Body       []Node             `json:"node"`
+	Span
+	Arguments FieldList `json:"arguments"`
+	Results   FieldList `json:"results"`
+	Body      []Node    `json:"node"`
+	Captures  []string  `json:"captures"`
 }
 
 // TypeConversion presentation in code:
 //
 //  x := string("Hi!")
+//  y := []byte("Bye!")
 type TypeConversion struct {
-	To    string `json:"to"`
-	Value Node   `json:"value"`
+	Span
+	To    TypeExpr `json:"to"`
+	Value Node     `json:"value"`
 }
 
 // BinaryExpression presentation in code:
@@ -105,49 +355,374 @@ type TypeConversion struct {
 //  //            |
 //  //     Binary expression
 type BinaryExpression struct {
+	Span
 	Operator  string `json:"operator"`
 	HasParens bool   `json:"has_parens"`
 	Left      Node   `json:"left"`
 	Right     Node   `json:"right"`
 }
 
-// File represents the whole parsed file with node statements.
-type File struct {
+// UnaryExpression presentation in code:
+//
+//  x := -y
+//  //   ^^
+//  //   ||
+//  //   | -- Operand
+//  //   |
+//  // Operator
+type UnaryExpression struct {
+	Span
+	Operator string `json:"operator"`
+	Operand  Node   `json:"operand"`
+}
+
+// AssignStatement presentation in code:
+//
+//  x = 1
+//  a, b = b, a
+//  y += 2
+//
+// or, when several variables are declared at once:
+//
+//  c, d := 1, 2
+type AssignStatement struct {
+	Span
+	Left     []Node `json:"left"`
+	Operator string `json:"operator"`
+	Right    []Node `json:"right"`
+}
+
+// IncDecStatement presentation in code:
+//
+//  i++
+//  j--
+type IncDecStatement struct {
+	Span
+	Operand  Node   `json:"operand"`
+	Operator string `json:"operator"`
+}
+
+// Block presentation in code:
+//
+//  {
+//      x := 1
+//  }
+type Block struct {
+	Span
 	Statements []Node `json:"statements"`
 }
 
-const (
-	TypeString       Type = "string"
-	TypeInteger      Type = "integer"
-	TypeFloat        Type = "float"
-	TypeOfIdentifier Type = "of-identifier"
-	TypeOfConversion Type = "of-conversion"
-	TypeUnknown      Type = "unknown"
-)
+// IfStatement presentation in code:
+//
+//  if x := f(); x > 0 {
+//      // ...
+//  } else if x < 0 {
+//      // ...
+//  } else {
+//      // ...
+//  }
+//
+// Init is nil if there's no init statement.
+// Else is either another IfStatement, a Block, or nil if there's no else branch.
+type IfStatement struct {
+	Span
+	Init      Node  `json:"init"`
+	Condition Node  `json:"condition"`
+	Body      Block `json:"body"`
+	Else      Node  `json:"else"`
+}
 
-func TypeFromKind(t token.Kind) Type {
-	switch t {
-	case token.Integer:
-		return TypeInteger
-	case token.Float:
-		return TypeFloat
-	case token.String:
-		return TypeString
-	case token.Identifier:
-		return TypeOfIdentifier
-	case token.Type:
-		return TypeOfConversion
-	}
-	return TypeUnknown
-}
-
-func (Value) node()            {}
-func (Variable) node()         {}
-func (Function) node()         {}
-func (FunctionArgument) node() {}
-func (Call) node()             {}
-func (CallArgument) node()     {}
-func (FunctionValue) node()    {}
-func (File) node()             {}
-func (TypeConversion) node()   {}
-func (BinaryExpression) node() {}
+// ForStatement presentation in code:
+//
+//  for i := 0; i < n; i++ {
+//      // ...
+//  }
+//
+// or, with the condition only:
+//
+//  for x < 10 {
+//      // ...
+//  }
+//
+// or, infinite:
+//
+//  for {
+//      // ...
+//  }
+//
+// Init, Condition and Post are nil if omitted.
+// Like in Go 1.22+, each iteration has its own copy of the variables
+// declared by Init, so closures created in the body capture the variables
+// of their own iteration, rather than the ones shared by the whole loop.
+type ForStatement struct {
+	Span
+	Init      Node  `json:"init"`
+	Condition Node  `json:"condition"`
+	Post      Node  `json:"post"`
+	Body      Block `json:"body"`
+}
+
+// RangeStatement presentation in code:
+//
+//  for k, v := range m {
+//      // ...
+//  }
+//
+// or, over an integer:
+//
+//  for range 10 {
+//      // ...
+//  }
+//
+// Key and Value are nil if omitted.
+// Define reports whether they're declared with ":=", rather than assigned with "=".
+// Like in Go 1.22+, the declared variables are per-iteration:
+// each iteration has its own copy of them.
+type RangeStatement struct {
+	Span
+	Key        Node  `json:"key"`
+	Value      Node  `json:"value"`
+	Define     bool  `json:"define"`
+	Expression Node  `json:"expression"`
+	Body       Block `json:"body"`
+}
+
+// SwitchStatement presentation in code:
+//
+//  switch x := f(); x {
+//  case 1, 2:
+//      // ...
+//  default:
+//      // ...
+//  }
+//
+// Init and Tag are nil if omitted; a switch without the tag
+// is the same as switching on true.
+type SwitchStatement struct {
+	Span
+	Init  Node         `json:"init"`
+	Tag   Node         `json:"tag"`
+	Cases []CaseClause `json:"cases"`
+}
+
+// TypeSwitchStatement presentation in code:
+//
+//  switch v := x.(type) {
+//  case int, float:
+//      // ...
+//  }
+//
+// Binding is the name declared in the switch guard ("v"), or empty if there's none.
+// Subject is the expression whose type is switched on ("x").
+type TypeSwitchStatement struct {
+	Span
+	Init    Node         `json:"init"`
+	Binding string       `json:"binding"`
+	Subject Node         `json:"subject"`
+	Cases   []CaseClause `json:"cases"`
+}
+
+// CaseClause presentation in code:
+//
+//  switch x {
+//  case 1, 2:
+//  //   ^^^^
+//  //    |
+//  // The case expressions
+//      // ...
+//  }
+//
+// In type switches, the case expressions are types.
+// Default clauses have no expressions.
+type CaseClause struct {
+	Span
+	Expressions []Node `json:"expressions"`
+	Default     bool   `json:"default"`
+	Body        []Node `json:"body"`
+}
+
+// FallthroughStatement presentation in code:
+//
+//  case 1:
+//      fallthrough
+type FallthroughStatement struct {
+	Span
+}
+
+// ReturnStatement presentation in code:
+//
+//  return x, nil
+//
+// Results is empty if the function returns nothing.
+type ReturnStatement struct {
+	Span
+	Results []Node `json:"results"`
+}
+
+// BranchStatement presentation in code:
+//
+//  break
+//  continue outer
+//  goto end
+//
+// Keyword is one of "break", "continue" and "goto".
+// Label is empty if omitted; goto always has it.
+type BranchStatement struct {
+	Span
+	Keyword string `json:"keyword"`
+	Label   string `json:"label"`
+}
+
+// LabeledStatement presentation in code:
+//
+//  outer:
+//      for {
+//          // ...
+//      }
+//
+// Statement is nil if the label is the last one in the block.
+type LabeledStatement struct {
+	Span
+	Label     string `json:"label"`
+	Statement Node   `json:"statement"`
+}
+
+// DeferStatement presentation in code:
+//
+//  defer f.Close()
+type DeferStatement struct {
+	Span
+	Call Call `json:"call"`
+}
+
+// GoStatement presentation in code:
+//
+//  go worker(jobs)
+type GoStatement struct {
+	Span
+	Call Call `json:"call"`
+}
+
+// SendStatement presentation in code:
+//
+//  ch <- v
+type SendStatement struct {
+	Span
+	Channel Node `json:"channel"`
+	Value   Node `json:"value"`
+}
+
+// SelectStatement presentation in code:
+//
+//  select {
+//  case v := <-ch:
+//      // ...
+//  case out <- v:
+//      // ...
+//  default:
+//      // ...
+//  }
+type SelectStatement struct {
+	Span
+	Cases []CommClause `json:"cases"`
+}
+
+// CommClause presentation in code:
+//
+//  select {
+//  case v, ok := <-ch:
+//  //   ^^^^^^^^^^^^^
+//  //         |
+//  //  The communication
+//      // ...
+//  }
+//
+// Communication is SendStatement, the receive UnaryExpression,
+// or Variable or AssignStatement with the receive on the right side.
+// It's nil in default clauses.
+type CommClause struct {
+	Span
+	Communication Node   `json:"communication"`
+	Default       bool   `json:"default"`
+	Body          []Node `json:"body"`
+}
+
+// BadExpr is a placeholder for the expression that failed to parse.
+// Its span covers the tokens the parser skipped.
+type BadExpr struct {
+	Span
+}
+
+// BadStmt is a placeholder for the statement or declaration that failed to parse.
+// Its span covers the tokens the parser skipped.
+type BadStmt struct {
+	Span
+}
+
+// ImportSpec presentation in code:
+//
+//  import (
+//      "fmt"
+//      str "strings"
+//  )
+//
+// Path is the unquoted import path.
+// Alias is empty if there's none, "." for dot imports, and "_" for blank imports.
+type ImportSpec struct {
+	Span
+	Path  string `json:"path"`
+	Alias string `json:"alias"`
+}
+
+// File represents the whole parsed file with the package name,
+// imports and node statements.
+type File struct {
+	Span
+	Package    string       `json:"package"`
+	Imports    []ImportSpec `json:"imports"`
+	Statements []Node       `json:"statements"`
+}
+
+func (Value) node()                {}
+func (Variable) node()             {}
+func (Function) node()             {}
+func (Call) node()                 {}
+func (CallArgument) node()         {}
+func (FunctionValue) node()        {}
+func (File) node()                 {}
+func (TypeConversion) node()       {}
+func (BinaryExpression) node()     {}
+func (UnaryExpression) node()      {}
+func (AssignStatement) node()      {}
+func (IncDecStatement) node()      {}
+func (Declaration) node()          {}
+func (ValueSpec) node()            {}
+func (ImportSpec) node()           {}
+func (Block) node()                {}
+func (IfStatement) node()          {}
+func (ForStatement) node()         {}
+func (RangeStatement) node()       {}
+func (SwitchStatement) node()      {}
+func (TypeSwitchStatement) node()  {}
+func (CaseClause) node()           {}
+func (FallthroughStatement) node() {}
+func (ReturnStatement) node()      {}
+func (BranchStatement) node()      {}
+func (LabeledStatement) node()     {}
+func (Field) node()                {}
+func (FieldList) node()            {}
+func (TypeDeclaration) node()      {}
+func (CompositeLiteral) node()     {}
+func (KeyValue) node()             {}
+func (SelectorExpr) node()         {}
+func (IndexExpr) node()            {}
+func (SliceExpr) node()            {}
+func (TypeAssertExpr) node()       {}
+func (Instantiation) node()        {}
+func (DeferStatement) node()       {}
+func (GoStatement) node()          {}
+func (SendStatement) node()        {}
+func (SelectStatement) node()      {}
+func (CommClause) node()           {}
+func (BadExpr) node()              {}
+func (BadStmt) node()              {}
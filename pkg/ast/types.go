@@ -0,0 +1,180 @@
+package ast
+
+// TypeExpr represents a type, as it's written in the code.
+type TypeExpr interface {
+	Node
+	typeExpr()
+}
+
+// ChanDirection represents the direction of the channel type.
+type ChanDirection string
+
+// NamedType presentation in code:
+//
+//  var x int
+//  //    ^
+//  //    |
+//  // The named type
+type NamedType struct {
+	Span
+	Name string `json:"name"`
+}
+
+// QualifiedType presentation in code:
+//
+//  var s fmt.Stringer
+type QualifiedType struct {
+	Span
+	Package string `json:"package"`
+	Name    string `json:"name"`
+}
+
+// PointerType presentation in code:
+//
+//  var p *int
+type PointerType struct {
+	Span
+	Elem TypeExpr `json:"elem"`
+}
+
+// SliceType presentation in code:
+//
+//  var xs []int
+type SliceType struct {
+	Span
+	Elem TypeExpr `json:"elem"`
+}
+
+// ArrayType presentation in code:
+//
+//  var xs [3]int
+//
+// Length is nil if it's written as "...", which is only allowed in composite literals.
+type ArrayType struct {
+	Span
+	Length Node     `json:"length"`
+	Elem   TypeExpr `json:"elem"`
+}
+
+// MapType presentation in code:
+//
+//  var m map[string]int
+type MapType struct {
+	Span
+	Key   TypeExpr `json:"key"`
+	Value TypeExpr `json:"value"`
+}
+
+// FuncType presentation in code:
+//
+//  var f func(int) (string, error)
+type FuncType struct {
+	Span
+	Arguments FieldList `json:"arguments"`
+	Results   FieldList `json:"results"`
+}
+
+// ChanType presentation in code:
+//
+//  var ch chan int
+//  var send chan<- int
+//  var receive <-chan int
+type ChanType struct {
+	Span
+	Direction ChanDirection `json:"direction"`
+	Elem      TypeExpr      `json:"elem"`
+}
+
+// StructType presentation in code:
+//
+//  type Point struct {
+//      X, Y int
+//      name string `json:"name"`
+//      fmt.Stringer
+//  }
+//
+// Embedded fields have no names.
+type StructType struct {
+	Span
+	Fields FieldList `json:"fields"`
+}
+
+// InterfaceType presentation in code:
+//
+//  type Shape interface {
+//      Area() float
+//      fmt.Stringer
+//  }
+//
+// Methods have a single name and FuncType as the type.
+// Embedded elements have no names, like the embedded fields of StructType;
+// their type is either an interface name or UnionType.
+type InterfaceType struct {
+	Span
+	Methods FieldList `json:"methods"`
+}
+
+// UnionType presentation in code:
+//
+//  type Number interface {
+//      ~int | ~float
+//  }
+//
+// UnionType only appears in interfaces and type constraints.
+type UnionType struct {
+	Span
+	Terms []TypeTerm `json:"terms"`
+}
+
+// TypeTerm is a term of UnionType.
+// Tilde reports whether the term is written as "~T",
+// meaning all types whose underlying type is T.
+type TypeTerm struct {
+	Tilde bool     `json:"tilde"`
+	Type  TypeExpr `json:"type"`
+}
+
+// InstantiatedType presentation in code:
+//
+//  var s Stack[int]
+//  var m Pair[string, int]
+//
+// Type is either NamedType or QualifiedType.
+type InstantiatedType struct {
+	Span
+	Type      TypeExpr   `json:"type"`
+	Arguments []TypeExpr `json:"arguments"`
+}
+
+// A channel direction.
+const (
+	ChanBoth    ChanDirection = "both"
+	ChanSend    ChanDirection = "send"
+	ChanReceive ChanDirection = "receive"
+)
+
+func (NamedType) node()        {}
+func (QualifiedType) node()    {}
+func (PointerType) node()      {}
+func (SliceType) node()        {}
+func (ArrayType) node()        {}
+func (MapType) node()          {}
+func (FuncType) node()         {}
+func (ChanType) node()         {}
+func (StructType) node()       {}
+func (InterfaceType) node()    {}
+func (UnionType) node()        {}
+func (InstantiatedType) node() {}
+
+func (NamedType) typeExpr()        {}
+func (QualifiedType) typeExpr()    {}
+func (PointerType) typeExpr()      {}
+func (SliceType) typeExpr()        {}
+func (ArrayType) typeExpr()        {}
+func (MapType) typeExpr()          {}
+func (FuncType) typeExpr()         {}
+func (ChanType) typeExpr()         {}
+func (StructType) typeExpr()       {}
+func (InterfaceType) typeExpr()    {}
+func (UnionType) typeExpr()        {}
+func (InstantiatedType) typeExpr() {}
@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/dywoq/minigo/pkg/token"
+)
+
+// DefaultErrorLimit is the number of errors the parser reports by default.
+const DefaultErrorLimit = 10
+
+// Error is a syntax error at Pos.
+type Error struct {
+	Pos token.Position
+	Err error
+}
+
+// Error returns the message of the underlying error.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// errorAt returns Error at pos. The message is followed by the position,
+// like "expected type, got "}" at 3:5".
+func errorAt(pos token.Position, format string, args ...any) error {
+	return &Error{Pos: pos, Err: fmt.Errorf(format+" at %v", append(args, pos)...)}
+}
+
+// ErrorList is a list of syntax errors.
+type ErrorList []*Error
+
+// Sort sorts the list by the positions of the errors.
+func (l ErrorList) Sort() {
+	slices.SortStableFunc(l, func(a, b *Error) int {
+		return a.Pos.Position - b.Pos.Position
+	})
+}
+
+// RemoveMultiples sorts the list and removes all but the first error per line.
+// Errors on the same line are usually caused by the same mistake.
+func (l *ErrorList) RemoveMultiples() {
+	l.Sort()
+	*l = slices.CompactFunc(*l, func(a, b *Error) bool {
+		return a.Pos.Line == b.Pos.Line
+	})
+}
+
+// Error returns the message of the first error
+// and the number of the other ones.
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Err returns the list as an error, or nil if the list is empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
@@ -3,7 +3,6 @@ package parser
 import (
 	"fmt"
 	"slices"
-	"unicode"
 
 	"github.com/dywoq/minigo/pkg/ast"
 	"github.com/dywoq/minigo/pkg/token"
@@ -20,6 +19,15 @@ type context interface {
 	expectLiterals(literals ...string) (*token.Token, error)
 	expectKind(kind token.Kind) (*token.Token, error)
 	expectKinds(kinds ...token.Kind) (*token.Token, error)
+	expectTerminator() error
+	exprLevel() int
+	setExprLevel(lev int)
+	inSwitchHeader() bool
+	setSwitchHeader(b bool)
+	resolver() *resolver
+	span(start *token.Token) ast.Span
+	addError(err error)
+	recoverFrom(start *token.Token, lev int, err error)
 }
 
 type mini func(context) (ast.Node, error)
@@ -37,7 +45,7 @@ func (e *expectError) Error() string {
 	if len(e.kinds) > 0 {
 		return fmt.Sprintf("expected one of %v, got %v at %v", e.kinds, e.got.Kind, e.got.Position)
 	}
-	return fmt.Sprintf("unexpected token %v at %v", e.got, e.got.Position)
+	return fmt.Sprintf("unexpected %q at %v", e.got.Literal, e.got.Position)
 }
 
 func parseDeclaration(c context) (ast.Node, error) {
@@ -48,147 +56,1328 @@ func parseDeclaration(c context) (ast.Node, error) {
 
 	switch t.Kind {
 	case token.Identifier:
+		return parseSimpleStatement(c)
+
+	case token.Keyword:
+		switch t.Literal {
+		case "func":
+			return parseFunction(c)
+		case "var", "const":
+			return parseValueDeclaration(c)
+		case "type":
+			return parseTypeDeclaration(c)
+		case "import":
+			return nil, errorAt(*t.Position, "imports must appear before other declarations")
+		case "package":
+			return nil, errorAt(*t.Position, "unexpected package clause")
+		}
+		return nil, errorAt(*t.Position, "unexpected keyword %q", t.Literal)
+	}
+
+	return nil, errorAt(*t.Position, "unexpected %q at the start of declaration", t.Literal)
+}
+
+func parsePackageClause(c context) (string, error) {
+	_, err := c.expectLiteral("package")
+	if err != nil {
+		return "", err
+	}
+	name, err := c.expectKind(token.Identifier)
+	if err != nil {
+		return "", err
+	}
+	if name.Literal == "_" {
+		return "", errorAt(*name.Position, "invalid package name _")
+	}
+	return name.Literal, nil
+}
+
+func parseImportDeclaration(c context) ([]ast.ImportSpec, error) {
+	_, err := c.expectLiteral("import")
+	if err != nil {
+		return nil, err
+	}
+	if c.current().Literal != "(" {
+		spec, err := parseImportSpec(c)
+		if err != nil {
+			return nil, err
+		}
+		return []ast.ImportSpec{spec}, nil
+	}
+
+	c.advance(1)
+	var specs []ast.ImportSpec
+	for c.current().Literal != ")" && c.current().Kind != token.Eof {
+		t := c.current()
+		spec, err := parseImportSpec(c)
+		if err != nil {
+			c.recoverFrom(t, c.exprLevel(), err)
+			continue
+		}
+		specs = append(specs, spec)
+		if err := c.expectTerminator(); err != nil {
+			c.recoverFrom(t, c.exprLevel(), err)
+		}
+	}
+	_, err = c.expectLiteral(")")
+	if err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+func parseImportSpec(c context) (ast.ImportSpec, error) {
+	start := c.current()
+	alias := ""
+	if start.Kind == token.Identifier || start.Literal == "." {
+		alias = start.Literal
+		c.advance(1)
+	}
+	pathToken, err := c.expectKinds(token.String, token.RawString)
+	if err != nil {
+		return ast.ImportSpec{}, err
+	}
+	path, err := token.Unquote(pathToken.Literal)
+	if err != nil {
+		return ast.ImportSpec{}, err
+	}
+	if path == "" {
+		return ast.ImportSpec{}, errorAt(*pathToken.Position, "invalid import path")
+	}
+	return ast.ImportSpec{
+		Span:  c.span(start),
+		Path:  path,
+		Alias: alias,
+	}, nil
+}
+
+func parseTypeDeclaration(c context) (ast.Node, error) {
+	start, err := c.expectLiteral("type")
+	if err != nil {
+		return nil, err
+	}
+	name, err := c.expectKind(token.Identifier)
+	if err != nil {
+		return nil, err
+	}
+	decl := ast.TypeDeclaration{
+		Name:     name.Literal,
+		Exported: ast.IsExported(name.Literal),
+	}
+	c.resolver().declare(false, name.Literal)
+	if c.current().Literal == "[" && isTypeParams(c) {
+		decl.TypeParams, err = parseTypeParams(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.current().Literal == "=" {
+		decl.Alias = true
+		c.advance(1)
+	}
+	decl.Type, err = parseType(c)
+	if err != nil {
+		return nil, err
+	}
+	decl.Span = c.span(start)
+	return decl, nil
+}
+
+func parseValueDeclaration(c context) (ast.Node, error) {
+	keyword, err := c.expectLiterals("var", "const")
+	if err != nil {
+		return nil, err
+	}
+	decl := ast.Declaration{Keyword: keyword.Literal}
+	if c.current().Literal != "(" {
+		spec, err := parseValueSpec(c, keyword.Literal, 0)
+		if err != nil {
+			return nil, err
+		}
+		decl.Specs = append(decl.Specs, spec)
+		decl.Span = c.span(keyword)
+		return decl, nil
+	}
+
+	decl.Grouped = true
+	c.advance(1)
+	for iota := 0; c.current().Literal != ")" && c.current().Kind != token.Eof; iota++ {
+		// The spec that fails to parse is skipped, so the other ones are still parsed.
+		t := c.current()
+		spec, err := parseValueSpec(c, keyword.Literal, iota)
+		if err != nil {
+			c.recoverFrom(t, c.exprLevel(), err)
+			continue
+		}
+		decl.Specs = append(decl.Specs, spec)
+		if err := c.expectTerminator(); err != nil {
+			c.recoverFrom(t, c.exprLevel(), err)
+		}
+	}
+	_, err = c.expectLiteral(")")
+	if err != nil {
+		return nil, err
+	}
+	decl.Span = c.span(keyword)
+	return decl, nil
+}
+
+func parseValueSpec(c context, keyword string, iota int) (ast.ValueSpec, error) {
+	start := c.current()
+	spec := ast.ValueSpec{}
+	if keyword == "const" {
+		spec.Iota = iota
+	}
+	for {
+		name, err := c.expectKind(token.Identifier)
+		if err != nil {
+			return ast.ValueSpec{}, err
+		}
+		spec.Names = append(spec.Names, name.Literal)
+		if c.current().Literal != "," {
+			break
+		}
+		c.advance(1)
+	}
+
+	if isTypeStart(c.current()) {
+		typ, err := parseType(c)
+		if err != nil {
+			return ast.ValueSpec{}, err
+		}
+		spec.Type = typ
+		spec.Explicit = true
+	}
+
+	if c.current().Literal == "=" {
+		c.advance(1)
+		values, err := parseExpressionList(c)
+		if err != nil {
+			return ast.ValueSpec{}, err
+		}
+		spec.Values = values
+	}
+
+	switch {
+	case keyword == "var" && !spec.Explicit && len(spec.Values) == 0:
+		return ast.ValueSpec{}, errorAt(*start.Position, "missing variable type or initialization")
+	case keyword == "const" && len(spec.Values) == 0 && (spec.Explicit || iota == 0):
+		return ast.ValueSpec{}, errorAt(*start.Position, "missing initialization in const declaration")
+	}
+	if len(spec.Values) > 0 && len(spec.Values) != len(spec.Names) {
+		if keyword == "const" || len(spec.Values) > 1 || !isMultiValue(spec.Values[0]) {
+			return ast.ValueSpec{}, assignmentMismatch(start, len(spec.Names), len(spec.Values))
+		}
+	}
+
+	if !spec.Explicit && len(spec.Values) == len(spec.Names) {
+		spec.Type = inferType(spec.Values[0])
+		for _, val := range spec.Values[1:] {
+			if !sameType(inferType(val), spec.Type) {
+				spec.Type = nil
+				break
+			}
+		}
+	}
+	c.resolver().declare(keyword == "var", spec.Names...)
+	spec.Span = c.span(start)
+	return spec, nil
+}
+
+// parseStatement parses a statement inside a function body.
+func parseStatement(c context) (ast.Node, error) {
+	t := c.current()
+	if t.Literal == "{" {
+		return parseBlock(c)
+	}
+	if t.Kind == token.Keyword {
+		switch t.Literal {
+		case "func":
+			return parseSimpleStatement(c)
+		case "if":
+			return parseIfStatement(c)
+		case "for":
+			return parseForStatement(c)
+		case "switch":
+			return parseSwitchStatement(c)
+		case "select":
+			return parseSelectStatement(c)
+		case "defer", "go":
+			return parseDeferOrGoStatement(c)
+		case "fallthrough":
+			c.advance(1)
+			return ast.FallthroughStatement{Span: c.span(t)}, nil
+		case "return":
+			return parseReturnStatement(c)
+		case "break", "continue", "goto":
+			return parseBranchStatement(c)
+		}
+		return parseDeclaration(c)
+	}
+	if next := c.peek(1); t.Kind == token.Identifier && next != nil && next.Literal == ":" {
+		return parseLabeledStatement(c)
+	}
+	return parseSimpleStatement(c)
+}
+
+func parseDeferOrGoStatement(c context) (ast.Node, error) {
+	start, err := c.expectLiterals("defer", "go")
+	if err != nil {
+		return nil, err
+	}
+	x, err := parseExpression(c, 0)
+	if err != nil {
+		return nil, err
+	}
+	call, ok := x.(ast.Call)
+	if !ok {
+		return nil, errorAt(*start.Position, "expression in %s must be function call", start.Literal)
+	}
+	if start.Literal == "defer" {
+		return ast.DeferStatement{Span: c.span(start), Call: call}, nil
+	}
+	return ast.GoStatement{Span: c.span(start), Call: call}, nil
+}
+
+func parseReturnStatement(c context) (ast.Node, error) {
+	start, err := c.expectLiteral("return")
+	if err != nil {
+		return nil, err
+	}
+	stmt := ast.ReturnStatement{}
+	if t := c.current(); t.Literal != ";" && t.Literal != "}" && t.Kind != token.Eof {
+		stmt.Results, err = parseExpressionList(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+	stmt.Span = c.span(start)
+	return stmt, nil
+}
+
+func parseBranchStatement(c context) (ast.Node, error) {
+	keyword, err := c.expectLiterals("break", "continue", "goto")
+	if err != nil {
+		return nil, err
+	}
+	stmt := ast.BranchStatement{Keyword: keyword.Literal}
+	if c.current().Kind == token.Identifier || keyword.Literal == "goto" {
+		label, err := c.expectKind(token.Identifier)
+		if err != nil {
+			return nil, err
+		}
+		stmt.Label = label.Literal
+	}
+	stmt.Span = c.span(keyword)
+	return stmt, nil
+}
+
+func parseLabeledStatement(c context) (ast.Node, error) {
+	label, err := c.expectKind(token.Identifier)
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.expectLiteral(":")
+	if err != nil {
+		return nil, err
+	}
+	stmt := ast.LabeledStatement{Label: label.Literal}
+	if t := c.current(); t.Literal != "}" && t.Kind != token.Eof {
+		stmt.Statement, err = parseStatement(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+	stmt.Span = c.span(label)
+	return stmt, nil
+}
+
+func parseBlock(c context) (ast.Block, error) {
+	start := c.current()
+	r := c.resolver()
+	r.openScope()
+	defer r.closeScope()
+	statements, err := parseFunctionBodyDeclaration(c)
+	if err != nil {
+		return ast.Block{}, err
+	}
+	return ast.Block{Span: c.span(start), Statements: statements}, nil
+}
+
+func parseIfStatement(c context) (ast.Node, error) {
+	start, err := c.expectLiteral("if")
+	if err != nil {
+		return nil, err
+	}
+	r := c.resolver()
+	r.openScope()
+	defer r.closeScope()
+	if c.current().Literal == "{" {
+		return nil, errorAt(*start.Position, "missing condition in if statement")
+	}
+	lev := c.exprLevel()
+	c.setExprLevel(-1)
+
+	var init ast.Node
+	cond, err := parseSimpleStatement(c)
+	if err != nil {
+		return nil, err
+	}
+	if c.current().Literal == ";" {
+		c.advance(1)
+		init = cond
+		if c.current().Literal == "{" {
+			return nil, errorAt(*start.Position, "missing condition in if statement")
+		}
+		cond, err = parseExpression(c, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !isExpression(cond) {
+		return nil, errorAt(*start.Position, "expected condition in if statement, got a statement")
+	}
+	c.setExprLevel(lev)
+
+	body, err := parseBlock(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var els ast.Node
+	if c.current().Literal == "else" {
+		c.advance(1)
+		switch c.current().Literal {
+		case "if":
+			els, err = parseIfStatement(c)
+		case "{":
+			els, err = parseBlock(c)
+		default:
+			return nil, errorAt(*c.current().Position, "else must be followed by if or statement block")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ast.IfStatement{
+		Span:      c.span(start),
+		Init:      init,
+		Condition: cond,
+		Body:      body,
+		Else:      els,
+	}, nil
+}
+
+func parseForStatement(c context) (ast.Node, error) {
+	start, err := c.expectLiteral("for")
+	if err != nil {
+		return nil, err
+	}
+	r := c.resolver()
+	r.openScope()
+	defer r.closeScope()
+	lev := c.exprLevel()
+	c.setExprLevel(-1)
+
+	var init, cond, post ast.Node
+	if c.current().Literal == "range" {
+		c.advance(1)
+		expr, err := parseExpression(c, 0)
+		if err != nil {
+			return nil, err
+		}
+		c.setExprLevel(lev)
+		body, err := parseBlock(c)
+		if err != nil {
+			return nil, err
+		}
+		return ast.RangeStatement{
+			Span:       c.span(start),
+			Expression: expr,
+			Body:       body,
+		}, nil
+	}
+
+	if c.current().Literal != "{" {
+		if c.current().Literal != ";" {
+			cond, err = parseSimpleStatementOrRange(c, true)
+			if err != nil {
+				return nil, err
+			}
+			if stmt, ok := cond.(ast.RangeStatement); ok {
+				c.setExprLevel(lev)
+				stmt.Body, err = parseBlock(c)
+				if err != nil {
+					return nil, err
+				}
+				stmt.Span = c.span(start)
+				return stmt, nil
+			}
+		}
+		if c.current().Literal == ";" {
+			c.advance(1)
+			init, cond = cond, nil
+			if c.current().Literal != ";" {
+				cond, err = parseExpression(c, 0)
+				if err != nil {
+					return nil, err
+				}
+			}
+			_, err = c.expectLiteral(";")
+			if err != nil {
+				return nil, err
+			}
+			if postStart := c.current(); postStart.Literal != "{" {
+				post, err = parseSimpleStatement(c)
+				if err != nil {
+					return nil, err
+				}
+				s, ok := post.(ast.AssignStatement)
+				if _, variable := post.(ast.Variable); variable || (ok && s.Operator == ":=") {
+					return nil, errorAt(*postStart.Position, "cannot declare in post statement of for loop")
+				}
+			}
+		}
+		if cond != nil && !isExpression(cond) {
+			return nil, errorAt(*start.Position, "expected condition in for statement, got a statement")
+		}
+	}
+	c.setExprLevel(lev)
+
+	body, err := parseBlock(c)
+	if err != nil {
+		return nil, err
+	}
+	return ast.ForStatement{
+		Span:      c.span(start),
+		Init:      init,
+		Condition: cond,
+		Post:      post,
+		Body:      body,
+	}, nil
+}
+
+func parseSwitchStatement(c context) (ast.Node, error) {
+	start, err := c.expectLiteral("switch")
+	if err != nil {
+		return nil, err
+	}
+	r := c.resolver()
+	r.openScope()
+	defer r.closeScope()
+	lev := c.exprLevel()
+	c.setExprLevel(-1)
+	defer c.setExprLevel(lev)
+	guard := c.inSwitchHeader()
+	c.setSwitchHeader(true)
+	defer c.setSwitchHeader(guard)
+
+	var init, tag ast.Node
+	if c.current().Literal != "{" {
+		if c.current().Literal != ";" {
+			tag, err = parseSimpleStatement(c)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if c.current().Literal == ";" {
+			c.advance(1)
+			init, tag = tag, nil
+			if c.current().Literal != "{" {
+				tag, err = parseSimpleStatement(c)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	c.setExprLevel(lev)
+	c.setSwitchHeader(guard)
+	if binding, subject, ok := typeSwitchGuard(tag); ok {
+		stmt := ast.TypeSwitchStatement{
+			Init:    init,
+			Binding: binding,
+			Subject: subject,
+		}
+		r.declare(true, binding)
+		stmt.Cases, err = parseCaseClauses(c, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, clause := range stmt.Cases {
+			for _, n := range clause.Body {
+				if f, ok := n.(ast.FallthroughStatement); ok {
+					return nil, errorAt(f.Pos(), "cannot fallthrough in type switch")
+				}
+			}
+		}
+		stmt.Span = c.span(start)
+		return stmt, nil
+	}
+
+	if tag != nil && !isExpression(tag) {
+		return nil, errorAt(*start.Position, "expected switch expression, got a statement")
+	}
+	cases, err := parseCaseClauses(c, false)
+	if err != nil {
+		return nil, err
+	}
+	for i, clause := range cases {
+		for j, n := range clause.Body {
+			f, ok := n.(ast.FallthroughStatement)
+			if !ok {
+				continue
+			}
+			if j != len(clause.Body)-1 {
+				return nil, errorAt(f.Pos(), "fallthrough statement out of place")
+			}
+			if i == len(cases)-1 {
+				return nil, errorAt(f.Pos(), "cannot fallthrough final case in switch")
+			}
+		}
+	}
+	return ast.SwitchStatement{
+		Span:  c.span(start),
+		Init:  init,
+		Tag:   tag,
+		Cases: cases,
+	}, nil
+}
+
+func parseSelectStatement(c context) (ast.Node, error) {
+	start, err := c.expectLiteral("select")
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.expectLiteral("{")
+	if err != nil {
+		return nil, err
+	}
+	stmt := ast.SelectStatement{}
+	var defaultSeen bool
+	for c.current().Literal != "}" && c.current().Kind != token.Eof {
+		clause, err := parseCommClause(c)
+		if err != nil {
+			return nil, err
+		}
+		if clause.Default {
+			if defaultSeen {
+				return nil, errorAt(clause.Pos(), "multiple defaults in select")
+			}
+			defaultSeen = true
+		}
+		stmt.Cases = append(stmt.Cases, clause)
+	}
+	_, err = c.expectLiteral("}")
+	if err != nil {
+		return nil, err
+	}
+	stmt.Span = c.span(start)
+	return stmt, nil
+}
+
+func parseCommClause(c context) (ast.CommClause, error) {
+	t, err := c.expectLiterals("case", "default")
+	if err != nil {
+		return ast.CommClause{}, err
+	}
+	r := c.resolver()
+	r.openScope()
+	defer r.closeScope()
+
+	clause := ast.CommClause{Default: t.Literal == "default"}
+	if !clause.Default {
+		clause.Communication, err = parseSimpleStatement(c)
+		if err != nil {
+			return ast.CommClause{}, err
+		}
+		if !isCommunication(clause.Communication) {
+			return ast.CommClause{}, errorAt(*t.Position, "select case must be receive, send or assign recv")
+		}
+	}
+	_, err = c.expectLiteral(":")
+	if err != nil {
+		return ast.CommClause{}, err
+	}
+	clause.Body, err = parseStatementList(c)
+	if err != nil {
+		return ast.CommClause{}, err
+	}
+	clause.Span = c.span(t)
+	return clause, nil
+}
+
+// isCommunication reports whether the statement may be the communication of the select case.
+func isCommunication(n ast.Node) bool {
+	isReceive := func(n ast.Node) bool {
+		u, ok := n.(ast.UnaryExpression)
+		return ok && u.Operator == "<-"
+	}
+	switch v := n.(type) {
+	case ast.SendStatement:
+		return true
+	case ast.Variable:
+		return isReceive(v.Value)
+	case ast.AssignStatement:
+		return len(v.Left) <= 2 && len(v.Right) == 1 && (v.Operator == "=" || v.Operator == ":=") && isReceive(v.Right[0])
+	}
+	return isReceive(n)
+}
+
+// typeSwitchGuard reports whether the switch tag is a type switch guard,
+// like "x.(type)" or "v := x.(type)", and returns the bound name and the subject.
+func typeSwitchGuard(tag ast.Node) (string, ast.Node, bool) {
+	binding := ""
+	if v, ok := tag.(ast.Variable); ok {
+		binding, tag = v.Name, v.Value
+	}
+	assert, ok := tag.(ast.TypeAssertExpr)
+	if !ok || assert.Type != nil {
+		return "", nil, false
+	}
+	return binding, assert.Operand, true
+}
+
+// parseCaseClauses parses the body of a switch statement.
+// In type switches, the case expressions are parsed as types.
+func parseCaseClauses(c context, typeSwitch bool) ([]ast.CaseClause, error) {
+	_, err := c.expectLiteral("{")
+	if err != nil {
+		return nil, err
+	}
+	var clauses []ast.CaseClause
+	var defaultSeen bool
+	for c.current().Literal != "}" && c.current().Kind != token.Eof {
+		t, err := c.expectLiterals("case", "default")
+		if err != nil {
+			return nil, err
+		}
+		clause := ast.CaseClause{}
+		if t.Literal == "default" {
+			if defaultSeen {
+				return nil, errorAt(*t.Position, "multiple defaults in switch")
+			}
+			defaultSeen = true
+			clause.Default = true
+		} else if typeSwitch {
+			for {
+				typ, err := parseType(c)
+				if err != nil {
+					return nil, err
+				}
+				clause.Expressions = append(clause.Expressions, typ)
+				if c.current().Literal != "," {
+					break
+				}
+				c.advance(1)
+			}
+		} else {
+			clause.Expressions, err = parseExpressionList(c)
+			if err != nil {
+				return nil, err
+			}
+		}
+		_, err = c.expectLiteral(":")
+		if err != nil {
+			return nil, err
+		}
+		c.resolver().openScope()
+		clause.Body, err = parseStatementList(c)
+		c.resolver().closeScope()
+		if err != nil {
+			return nil, err
+		}
+		clause.Span = c.span(t)
+		clauses = append(clauses, clause)
+	}
+	_, err = c.expectLiteral("}")
+	if err != nil {
+		return nil, err
+	}
+	return clauses, nil
+}
+
+func parseCompositeLiteral(c context, typ ast.TypeExpr, start *token.Token) (ast.Node, error) {
+	_, err := c.expectLiteral("{")
+	if err != nil {
+		return nil, err
+	}
+	lev := c.exprLevel()
+	c.setExprLevel(lev + 1)
+	lit := ast.CompositeLiteral{Type: typ}
+	for c.current().Literal != "}" && c.current().Kind != token.Eof {
+		var elem ast.Node
+		if t, next := c.current(), c.peek(1); t.Kind == token.Identifier && next != nil && next.Literal == ":" && !hasKeyValues(typ) {
+			// The key that is a bare name may be a struct field,
+			// so it isn't resolved as the use of the variable.
+			c.advance(1)
+			elem = ast.Value{Span: c.span(t), Value: t.Literal, Kind: t.Kind}
+		} else {
+			elem, err = parseElement(c)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if c.current().Literal == ":" {
+			c.advance(1)
+			value, err := parseElement(c)
+			if err != nil {
+				return nil, err
+			}
+			elem = ast.KeyValue{Span: spanOf(elem, value), Key: elem, Value: value}
+		}
+		lit.Elements = append(lit.Elements, elem)
+		if c.current().Literal != "," {
+			break
+		}
+		c.advance(1)
+	}
+	c.setExprLevel(lev)
+	_, err = c.expectLiteral("}")
+	if err != nil {
+		return nil, err
+	}
+	lit.Span = c.span(start)
+	return lit, nil
+}
+
+// hasKeyValues reports whether the keys of the composite literal of the type are values,
+// like in maps, slices and arrays, rather than the names of struct fields.
+func hasKeyValues(typ ast.TypeExpr) bool {
+	switch typ.(type) {
+	case ast.MapType, ast.SliceType, ast.ArrayType:
+		return true
+	}
+	return false
+}
+
+// parseElement parses an element of the composite literal,
+// which may be another composite literal with the elided type.
+func parseElement(c context) (ast.Node, error) {
+	if t := c.current(); t.Literal == "{" {
+		return parseCompositeLiteral(c, nil, t)
+	}
+	return parseExpression(c, 0)
+}
+
+// assignmentMismatch returns the error for assigning the number of values
+// to the different number of variables.
+func assignmentMismatch(start *token.Token, variables, values int) error {
+	noun := "values"
+	if values == 1 {
+		noun = "value"
+	}
+	return errorAt(*start.Position, "assignment mismatch: %d variables but %d %s", variables, values, noun)
+}
+
+// isMultiValue reports whether the expression may have multiple values,
+// like "f()", "m[k]" or "v.(T)", so it can initialize several variables.
+func isMultiValue(n ast.Node) bool {
+	switch n.(type) {
+	case ast.Call, ast.IndexExpr, ast.TypeAssertExpr:
+		return true
+	}
+	return false
+}
+
+// isExpression reports whether n is an expression, rather than a statement.
+func isExpression(n ast.Node) bool {
+	switch n.(type) {
+	case ast.Variable, ast.AssignStatement, ast.IncDecStatement, ast.SendStatement:
+		return false
+	}
+	return true
+}
+
+// parseSimpleStatement parses a short variable declaration, an assignment,
+// an increment or decrement statement, or an expression statement.
+func parseSimpleStatement(c context) (ast.Node, error) {
+	return parseSimpleStatementOrRange(c, false)
+}
+
+// parseSimpleStatementOrRange is like parseSimpleStatement,
+// but if rangeOk is true, it also parses a range clause
+// into ast.RangeStatement without the body.
+func parseSimpleStatementOrRange(c context, rangeOk bool) (ast.Node, error) {
+	start := c.current()
+	if start.Kind == token.Identifier && !rangeOk {
 		next := c.peek(1)
 		if next != nil && next.Literal == ":=" {
-			name := t.Literal
 			c.advance(1)
-			return parseVariable(name, c)
+			return parseVariable(start, c)
+		}
+	}
+
+	left, ok := parseDefinedNames(c)
+	if !ok {
+		var err error
+		left, err = parseExpressionList(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	t := c.current()
+	switch {
+	case t.Literal == "=" || t.Literal == ":=" || t.Kind == token.AssignOperator:
+		if next := c.peek(1); rangeOk && t.Kind != token.AssignOperator && next != nil && next.Literal == "range" {
+			c.advance(1)
+			return parseRangeClause(c, start, left, t.Literal == ":=")
+		}
+		if t.Literal == ":=" && len(left) == 1 {
+			if v, ok := left[0].(ast.Value); ok && v.Kind == token.Identifier {
+				return parseVariable(start, c)
+			}
+		}
+		c.advance(1)
+		right, err := parseExpressionList(c)
+		if err != nil {
+			return nil, err
+		}
+		if t.Kind == token.AssignOperator && (len(left) > 1 || len(right) > 1) {
+			return nil, errorAt(*t.Position, "assignment operation %s requires single-valued expressions", t.Literal)
+		}
+		if len(left) != len(right) && (len(right) > 1 || !isMultiValue(right[0])) {
+			return nil, assignmentMismatch(start, len(left), len(right))
+		}
+		if t.Literal == ":=" {
+			for _, l := range left {
+				v, ok := l.(ast.Value)
+				if !ok || v.Kind != token.Identifier {
+					return nil, errorAt(*start.Position, "non-name on left side of :=")
+				}
+				c.resolver().declare(true, v.Value)
+			}
 		}
-		return nil, fmt.Errorf("unexpected identifier without := at %v", t.Position)
+		return ast.AssignStatement{
+			Span:     c.span(start),
+			Left:     left,
+			Operator: t.Literal,
+			Right:    right,
+		}, nil
 
-	case token.Keyword:
-		if t.Literal == "func" {
-			return parseFunction(c)
+	case t.Literal == "<-":
+		if len(left) > 1 {
+			return nil, errorAt(*t.Position, "expected 1 expression before <-")
+		}
+		c.advance(1)
+		value, err := parseExpression(c, 0)
+		if err != nil {
+			return nil, err
+		}
+		return ast.SendStatement{
+			Span:    c.span(start),
+			Channel: left[0],
+			Value:   value,
+		}, nil
+
+	case t.Kind == token.IncDecOperator:
+		if len(left) > 1 {
+			return nil, errorAt(*t.Position, "expected 1 expression before %s", t.Literal)
 		}
-		return nil, fmt.Errorf("unexpected keyword %q at %v", t.Literal, t.Position)
+		c.advance(1)
+		return ast.IncDecStatement{
+			Span:     c.span(start),
+			Operand:  left[0],
+			Operator: t.Literal,
+		}, nil
 	}
 
-	return nil, fmt.Errorf("unexpected declaration start: %v", t)
+	if len(left) > 1 {
+		return nil, errorAt(*start.Position, "expected 1 expression")
+	}
+	return left[0], nil
 }
 
-func parseVariable(name string, c context) (ast.Node, error) {
-	exported := false
-	_, err := c.expectLiteral(":=")
+// parseDefinedNames parses the names on the left side of ":=", like "a, b" in "a, b := f()",
+// so they aren't resolved as the uses of the variables. If the current tokens aren't
+// such names, it returns false and parses nothing.
+func parseDefinedNames(c context) ([]ast.Node, bool) {
+	n := 0
+	for {
+		name, next := c.peek(n), c.peek(n+1)
+		if name == nil || next == nil || name.Kind != token.Identifier {
+			return nil, false
+		}
+		n += 2
+		if next.Literal == ":=" {
+			break
+		}
+		if next.Literal != "," {
+			return nil, false
+		}
+	}
+	var names []ast.Node
+	for i := 0; i < n; i += 2 {
+		t := c.current()
+		c.advance(1)
+		names = append(names, ast.Value{Span: c.span(t), Value: t.Literal, Kind: t.Kind})
+		if i+2 < n {
+			c.advance(1)
+		}
+	}
+	return names, true
+}
+
+func parseRangeClause(c context, start *token.Token, left []ast.Node, define bool) (ast.Node, error) {
+	_, err := c.expectLiteral("range")
 	if err != nil {
 		return nil, err
 	}
-	val, kind, err := parseExpression(c, 0)
+	if len(left) > 2 {
+		return nil, errorAt(*start.Position, "range clause permits at most two iteration variables")
+	}
+	if define {
+		for _, l := range left {
+			if v, ok := l.(ast.Value); !ok || v.Kind != token.Identifier {
+				return nil, errorAt(*start.Position, "non-name on left side of :=")
+			}
+		}
+	}
+	expr, err := parseExpression(c, 0)
 	if err != nil {
 		return nil, err
 	}
+	if define {
+		for _, l := range left {
+			c.resolver().declare(true, l.(ast.Value).Value)
+		}
+	}
+	stmt := ast.RangeStatement{
+		Key:        left[0],
+		Define:     define,
+		Expression: expr,
+	}
+	if len(left) == 2 {
+		stmt.Value = left[1]
+	}
+	return stmt, nil
+}
+
+func parseExpressionList(c context) ([]ast.Node, error) {
+	var list []ast.Node
+	for {
+		expr, err := parseExpression(c, 0)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, expr)
+		if c.current().Literal != "," {
+			break
+		}
+		c.advance(1)
+	}
+	return list, nil
+}
 
-	if unicode.IsUpper(rune(name[0])) {
-		exported = true
+func parseVariable(name *token.Token, c context) (ast.Node, error) {
+	_, err := c.expectLiteral(":=")
+	if err != nil {
+		return nil, err
+	}
+	val, err := parseExpression(c, 0)
+	if err != nil {
+		return nil, err
 	}
 
+	c.resolver().declare(true, name.Literal)
 	return ast.Variable{
-		Name:     name,
-		Type:     ast.TypeFromKind(kind),
+		Span:     c.span(name),
+		Name:     name.Literal,
+		Type:     inferType(val),
 		Value:    val,
-		Exported: exported,
+		Exported: ast.IsExported(name.Literal),
 	}, nil
 }
 
-func parseValue(c context) (ast.Node, token.Kind, error) {
-	t := c.current()
-	if t == nil {
-		return nil, token.Illegal, fmt.Errorf("unexpected EOF in value")
+// parseValue parses an operand followed by any number of selectors, indexes,
+// slices, type assertions, calls and composite literals, like "a.b[c](d).e".
+func parseValue(c context) (ast.Node, error) {
+	start := c.current()
+	x, err := parseOperand(c)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch c.current().Literal {
+		case ".":
+			x, err = parseSelectorOrTypeAssertion(c, start, x)
+		case "[":
+			x, err = parseIndexOrSlice(c, start, x)
+		case "(":
+			x, err = parseFunctionCall(c, start, x)
+		case "{":
+			typ, ok := typeFromExpression(x)
+			if !ok || c.exprLevel() < 0 {
+				return x, nil
+			}
+			x, err = parseCompositeLiteral(c, typ, start)
+		default:
+			return x, nil
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
+}
 
-	if t.Literal == "(" {
+// The postfix parsers below get the start token of the whole expression, x included.
+
+func parseSelectorOrTypeAssertion(c context, start *token.Token, x ast.Node) (ast.Node, error) {
+	dot, err := c.expectLiteral(".")
+	if err != nil {
+		return nil, err
+	}
+	t := c.current()
+	switch {
+	case t.Kind == token.Identifier:
 		c.advance(1)
-		expr, kind, err := parseExpression(c, 0)
-		if err != nil {
-			return nil, token.Illegal, err
+		return ast.SelectorExpr{Span: c.span(start), Operand: x, Selector: t.Literal}, nil
+
+	case t.Literal == "(":
+		c.advance(1)
+		assert := ast.TypeAssertExpr{Operand: x}
+		if typ := c.current(); typ.Literal == "type" {
+			// ".(type)" must end the tag of the switch statement, like in "switch v := x.(type) {".
+			rparen, next := c.peek(1), c.peek(2)
+			if !c.inSwitchHeader() || rparen == nil || rparen.Literal != ")" || next == nil || next.Literal != "{" {
+				return nil, errorAt(*typ.Position, "use of .(type) outside type switch")
+			}
+			c.advance(1)
+		} else {
+			assert.Type, err = parseType(c)
+			if err != nil {
+				return nil, err
+			}
 		}
 		_, err = c.expectLiteral(")")
 		if err != nil {
-			return nil, token.Illegal, err
+			return nil, err
 		}
+		assert.Span = c.span(start)
+		return assert, nil
+	}
+	return nil, errorAt(*dot.Position, "expected selector or type assertion")
+}
 
-		if bin, ok := expr.(ast.BinaryExpression); ok {
-			bin.HasParens = true
-			return bin, kind, nil
-		}
-		return expr, kind, nil
+// parseIndexOrSlice parses an index, a slice or an explicit instantiation,
+// like "xs[i]", "s[1:3]" or "Map[int, string]".
+func parseIndexOrSlice(c context, start *token.Token, x ast.Node) (ast.Node, error) {
+	lbrack, err := c.expectLiteral("[")
+	if err != nil {
+		return nil, err
 	}
+	lev := c.exprLevel()
+	c.setExprLevel(lev + 1)
 
-	if t.Literal == "func" {
-		node, err := parseFunctionValue(c)
+	var index [3]ast.Node
+	colons := 0
+	if c.current().Literal != ":" {
+		index[0], err = parseTypeOrExpression(c)
 		if err != nil {
-			return nil, token.Illegal, err
+			return nil, err
 		}
-		return node, token.Identifier, nil
 	}
-
-	if t.Literal == "func" {
+	if _, isType := index[0].(ast.TypeExpr); isType || c.current().Literal == "," {
+		args := []ast.Node{index[0]}
+		for c.current().Literal == "," {
+			c.advance(1)
+			arg, err := parseTypeOrExpression(c)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+		}
+		c.setExprLevel(lev)
+		_, err = c.expectLiteral("]")
+		if err != nil {
+			return nil, err
+		}
+		inst := ast.Instantiation{Span: c.span(start), Operand: x}
+		for _, arg := range args {
+			typ, ok := arg.(ast.TypeExpr)
+			if !ok {
+				typ, ok = typeFromExpression(arg)
+			}
+			if !ok {
+				return nil, errorAt(*lbrack.Position, "expected type argument")
+			}
+			inst.TypeArguments = append(inst.TypeArguments, typ)
+		}
+		return inst, nil
+	}
+	for colons < 2 && c.current().Literal == ":" {
+		colons++
 		c.advance(1)
+		if t := c.current(); t.Literal != ":" && t.Literal != "]" {
+			index[colons], err = parseExpression(c, 0)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	c.setExprLevel(lev)
+	_, err = c.expectLiteral("]")
+	if err != nil {
+		return nil, err
+	}
 
-		args, err := parseFunctionArgumentsDeclaration(c)
-		if err != nil {
-			return nil, token.Illegal, err
+	if colons == 0 {
+		return ast.IndexExpr{Span: c.span(start), Operand: x, Index: index[0]}, nil
+	}
+	if colons == 2 {
+		if index[1] == nil {
+			return nil, errorAt(*lbrack.Position, "middle index required in 3-index slice")
 		}
+		if index[2] == nil {
+			return nil, errorAt(*lbrack.Position, "final index required in 3-index slice")
+		}
+	}
+	return ast.SliceExpr{
+		Span:    c.span(start),
+		Operand: x,
+		Low:     index[0],
+		High:    index[1],
+		Max:     index[2],
+		Slice3:  colons == 2,
+	}, nil
+}
+
+// parseTypeOrExpression parses a type argument or an index.
+// The ones that start like a type, like "[]int" or "map[string]int", are parsed as types,
+// and the others are parsed as expressions.
+func parseTypeOrExpression(c context) (ast.Node, error) {
+	t := c.current()
+	next := c.peek(1)
+	isType := false
+	switch {
+	case t.Kind == token.Type:
+		isType = next == nil || next.Literal != "("
+	case t.Kind == token.Keyword:
+		isType = slices.Contains([]string{"map", "chan", "func", "struct", "interface"}, t.Literal)
+	case t.Literal == "[":
+		isType = true
+	}
+	if !isType {
+		return parseExpression(c, 0)
+	}
+	typ, err := parseType(c)
+	if err != nil {
+		return nil, err
+	}
+	if c.current().Literal == "{" || c.current().Literal == "(" {
+		return nil, errorAt(*c.current().Position, "unexpected %q after type argument", c.current().Literal)
+	}
+	return typ, nil
+}
 
-		retType := parseFunctionReturnTypeDeclaration(c)
+func parseOperand(c context) (ast.Node, error) {
+	t := c.current()
+	if t == nil {
+		return nil, fmt.Errorf("unexpected EOF in value")
+	}
 
-		body, err := parseFunctionBodyDeclaration(c)
+	if t.Literal == "(" {
+		c.advance(1)
+		c.setExprLevel(c.exprLevel() + 1)
+		expr, err := parseExpression(c, 0)
+		if err != nil {
+			return nil, err
+		}
+		c.setExprLevel(c.exprLevel() - 1)
+		_, err = c.expectLiteral(")")
 		if err != nil {
-			return nil, token.Illegal, err
+			return nil, err
 		}
 
-		return ast.FunctionValue{
-			Arguments:  args,
-			ReturnType: retType,
-			Body:       body,
-		}, token.Identifier, nil
+		if bin, ok := expr.(ast.BinaryExpression); ok {
+			bin.HasParens = true
+			bin.Span = c.span(t)
+			return bin, nil
+		}
+		return expr, nil
+	}
+
+	if t.Literal == "func" {
+		return parseFunctionValue(c)
 	}
 
 	switch t.Kind {
-	case token.Integer, token.Float, token.String:
+	case token.Integer, token.Float, token.String, token.RawString, token.Char:
 		c.advance(1)
-		return ast.Value{Value: t.Literal}, t.Kind, nil
+		return ast.Value{Span: c.span(t), Value: t.Literal, Kind: t.Kind}, nil
 
 	case token.Type, token.Identifier:
-		next := c.peek(1)
-		if next != nil && next.Literal == "(" && t.Kind == token.Identifier {
-			node, err := parseFunctionCall(c)
-			if err != nil {
-				return nil, token.Illegal, err
-			}
-			return node, token.Identifier, nil
+		if next := c.peek(1); next != nil && next.Literal == "(" && t.Kind == token.Type {
+			return parseTypeConversion(c)
 		}
 
-		if next != nil && next.Literal == "(" && t.Kind == token.Type {
-			node, err := parseTypeConversion(c)
-			if err != nil {
-				return nil, token.Illegal, err
-			}
-			return node, token.Type, nil
+		if t.Kind == token.Identifier {
+			c.resolver().use(t.Literal)
 		}
-
 		c.advance(1)
-		return ast.Value{Value: t.Literal}, t.Kind, nil
+		return ast.Value{Span: c.span(t), Value: t.Literal, Kind: t.Kind}, nil
+	}
+
+	if isTypeStart(t) && t.Literal != "*" && t.Literal != "<-" {
+		typ, err := parseType(c)
+		if err != nil {
+			return nil, err
+		}
+		switch c.current().Literal {
+		case "{":
+			return parseCompositeLiteral(c, typ, t)
+		case "(":
+			return parseTypeConversionTo(c, t, typ)
+		}
+		// Like in Go, a bare type is an operand too,
+		// like the argument of "make(chan int, 1)".
+		return typ, nil
 	}
 
-	return nil, token.Illegal, fmt.Errorf("unknown value: %v", t)
+	// Record the error and continue with the placeholder, so the rest
+	// of the statement is still parsed. The tokens that may end the expression
+	// are left for the caller.
+	c.addError(errorAt(*t.Position, "expected operand, got %q", t.Literal))
+	if t.Kind != token.Eof && t.Kind != token.Keyword && !slices.Contains([]string{";", ",", ":", ")", "]", "{", "}"}, t.Literal) {
+		c.advance(1)
+	}
+	return ast.BadExpr{Span: c.span(t)}, nil
 }
 
 var precedence = map[string]int{
-	"+": 1,
-	"-": 1,
-	"*": 2,
-	"/": 2,
+	"||": 1,
+	"&&": 2,
+	"==": 3,
+	"!=": 3,
+	"<":  3,
+	"<=": 3,
+	">":  3,
+	">=": 3,
+	"+":  4,
+	"-":  4,
+	"|":  4,
+	"^":  4,
+	"*":  5,
+	"/":  5,
+	"%":  5,
+	"<<": 5,
+	">>": 5,
+	"&":  5,
+	"&^": 5,
 }
 
-func parseExpression(c context, minPrec int) (ast.Node, token.Kind, error) {
-	left, kind, err := parseValue(c)
+func parseExpression(c context, minPrec int) (ast.Node, error) {
+	left, err := parseUnaryExpression(c)
 	if err != nil {
-		return nil, token.Illegal, err
+		return nil, err
 	}
 
 	for {
 		t := c.current()
-		if t == nil || !slices.Contains(token.BinaryOperators, t.Literal) {
+		if t == nil || t.Kind != token.BinaryOperator {
 			break
 		}
 
@@ -200,38 +1389,86 @@ func parseExpression(c context, minPrec int) (ast.Node, token.Kind, error) {
 		op := t.Literal
 		c.advance(1)
 
-		right, rKind, err := parseExpression(c, opPrec+1)
+		right, err := parseExpression(c, opPrec+1)
 		if err != nil {
-			return nil, token.Illegal, err
+			return nil, err
 		}
 
 		left = ast.BinaryExpression{
+			Span:     spanOf(left, right),
 			Left:     left,
 			Operator: op,
 			Right:    right,
 		}
-		kind = rKind
 	}
 
-	return left, kind, nil
+	return left, nil
 }
 
-func parseFunction(c context) (ast.Node, error) {
-	_, err := c.expectLiteral("func")
+// spanOf returns the span from the start of the first node to the end of the last one.
+func spanOf(first, last ast.Node) ast.Span {
+	return ast.Span{From: first.Pos(), To: last.End()}
+}
+
+func parseUnaryExpression(c context) (ast.Node, error) {
+	t := c.current()
+	if t == nil || (t.Kind != token.BinaryOperator && t.Kind != token.UnaryOperator) || !slices.Contains(token.UnaryOperators, t.Literal) {
+		return parseValue(c)
+	}
+	if t.Literal == "~" {
+		return nil, errorAt(*t.Position, "cannot use ~ outside of interface or type constraint")
+	}
+	c.advance(1)
+	operand, err := parseUnaryExpression(c)
 	if err != nil {
 		return nil, err
 	}
+	// Like in Go, "<-chan int" is the receive-only channel type
+	// rather than the receive from the channel type.
+	if ch, ok := operand.(ast.ChanType); ok && t.Literal == "<-" && ch.Direction == ast.ChanBoth {
+		ch.Direction = ast.ChanReceive
+		ch.Span = c.span(t)
+		return ch, nil
+	}
+	return ast.UnaryExpression{
+		Span:     c.span(t),
+		Operator: t.Literal,
+		Operand:  operand,
+	}, nil
+}
 
-	nameToken, err := c.expectKind(token.Identifier)
+func parseFunction(c context) (ast.Node, error) {
+	start, err := c.expectLiteral("func")
 	if err != nil {
 		return nil, err
 	}
+	r := c.resolver()
+	r.openScope()
+	defer r.closeScope()
 
-	exported := false
+	var receiver *ast.Field
+	if c.current().Literal == "(" {
+		receiver, err = parseReceiver(c)
+		if err != nil {
+			return nil, err
+		}
+	}
 
+	nameToken, err := c.expectKind(token.Identifier)
+	if err != nil {
+		return nil, err
+	}
 	name := nameToken.Literal
-	if unicode.IsUpper(rune(name[0])) {
-		exported = true
+
+	var typeParams ast.FieldList
+	if c.current().Literal == "[" {
+		if receiver != nil {
+			return nil, errorAt(*c.current().Position, "methods cannot have type parameters")
+		}
+		typeParams, err = parseTypeParams(c)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	args, err := parseFunctionArgumentsDeclaration(c)
@@ -239,41 +1476,75 @@ func parseFunction(c context) (ast.Node, error) {
 		return nil, err
 	}
 
-	retType := parseFunctionReturnTypeDeclaration(c)
+	results, err := parseFunctionResultsDeclaration(c)
+	if err != nil {
+		return nil, err
+	}
 
+	if receiver != nil {
+		r.declare(true, receiver.Names...)
+	}
+	r.declare(false, fieldNames(typeParams)...)
+	r.declare(true, fieldNames(args)...)
+	r.declare(true, fieldNames(results)...)
 	body, err := parseFunctionBodyDeclaration(c)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	return ast.Function{
+		Span:       c.span(start),
 		Name:       name,
-		ReturnType: retType,
+		Receiver:   receiver,
+		TypeParams: typeParams,
 		Arguments:  args,
+		Results:    results,
 		Body:       body,
-		Exported:   exported,
+		Exported:   ast.IsExported(name),
 	}, nil
 }
 
-func parseFunctionCall(c context) (ast.Node, error) {
-	fnToken, err := c.expectKind(token.Identifier)
+// parseReceiver parses the receiver of the method, like "(p *Point)".
+func parseReceiver(c context) (*ast.Field, error) {
+	start := c.current()
+	list, err := parseFieldList(c, false)
 	if err != nil {
 		return nil, err
 	}
-	fnName := fnToken.Literal
-	_, err = c.expectLiteral("(")
+	if list.Len() != 1 {
+		return nil, errorAt(*start.Position, "method has %d receivers", list.Len())
+	}
+	receiver := list.Fields[0]
+	typ := receiver.Type
+	if p, ok := typ.(ast.PointerType); ok {
+		typ = p.Elem
+	}
+	if inst, ok := typ.(ast.InstantiatedType); ok {
+		typ = inst.Type
+	}
+	if _, ok := typ.(ast.NamedType); !ok {
+		return nil, errorAt(*start.Position, "invalid receiver type")
+	}
+	return &receiver, nil
+}
+
+func parseFunctionCall(c context, start *token.Token, fn ast.Node) (ast.Node, error) {
+	_, err := c.expectLiteral("(")
 	if err != nil {
 		return nil, err
 	}
+	lev := c.exprLevel()
+	c.setExprLevel(lev + 1)
 	var args []ast.CallArgument
 	for !c.eof() && c.current().Literal != ")" {
-		val, kind, err := parseExpression(c, 0)
+		val, err := parseExpression(c, 0)
 		if err != nil {
 			return nil, err
 		}
 
 		args = append(args, ast.CallArgument{
-			Type:  kind,
+			Span:  spanOf(val, val),
+			Type:  inferType(val),
 			Value: val,
 		})
 
@@ -283,73 +1554,124 @@ func parseFunctionCall(c context) (ast.Node, error) {
 			break
 		}
 	}
+	c.setExprLevel(lev)
 	_, err = c.expectLiteral(")")
 	if err != nil {
 		return nil, err
 	}
 	return ast.Call{
-		Identifier: fnName,
-		Arguments:  args,
+		Span:      c.span(start),
+		Function:  fn,
+		Arguments: args,
 	}, nil
 }
 
-func parseFunctionArgumentsDeclaration(c context) ([]ast.FunctionArgument, error) {
-	_, err := c.expectLiteral("(")
+func parseFunctionArgumentsDeclaration(c context) (ast.FieldList, error) {
+	return parseFieldList(c, true)
+}
+
+func parseFunctionResultsDeclaration(c context) (ast.FieldList, error) {
+	t := c.current()
+	switch {
+	case t.Literal == "(":
+		return parseFieldList(c, false)
+	case isTypeStart(t):
+		typ, err := parseType(c)
+		if err != nil {
+			return ast.FieldList{}, err
+		}
+		return ast.FieldList{
+			Span:   spanOf(typ, typ),
+			Fields: []ast.Field{{Span: spanOf(typ, typ), Type: typ}},
+		}, nil
+	}
+	return ast.FieldList{}, nil
+}
+
+// parseFieldList parses the parenthesized list of arguments or results.
+// Like in Go, either all of the fields are named, or none of them are,
+// and consecutive names may share the type:
+//
+//	(a, b int, c string)
+//	(int, string)
+func parseFieldList(c context, variadicOk bool) (ast.FieldList, error) {
+	lparen, err := c.expectLiteral("(")
 	if err != nil {
-		return nil, err
+		return ast.FieldList{}, err
 	}
-	var args []ast.FunctionArgument
-	var variadicSeen bool
-	for !c.eof() && c.current().Literal != ")" {
-		argNameToken, err := c.expectKind(token.Identifier)
-		if err != nil {
-			return nil, err
+
+	type entry struct {
+		start    *token.Token
+		span     ast.Span
+		name     string
+		typ      ast.TypeExpr
+		variadic bool
+	}
+	var entries []entry
+	named := false
+	for c.current().Literal != ")" && c.current().Kind != token.Eof {
+		e := entry{start: c.current()}
+		if next := c.peek(1); e.start.Kind == token.Identifier && next != nil && (next.Literal == "..." || (isTypeStart(next) && next.Literal != "(")) {
+			e.name = e.start.Literal
+			named = true
+			c.advance(1)
 		}
-		isVariadic := false
 		if c.current().Literal == "..." {
-			if variadicSeen {
-				return nil, fmt.Errorf("only one variadic parameter allowed")
-			}
-			isVariadic = true
-			variadicSeen = true
+			e.variadic = true
 			c.advance(1)
 		}
-		argTypeToken, err := c.expectKinds(token.Type, token.Identifier)
+		e.typ, err = parseType(c)
 		if err != nil {
-			return nil, err
-		}
-
-		arg := ast.FunctionArgument{
-			Identifier: argNameToken.Literal,
-			Type:       argTypeToken.Kind,
-			Variadic:   isVariadic,
-		}
-		args = append(args, arg)
-		if variadicSeen && c.current().Literal == "," {
-			return nil, fmt.Errorf("variadic parameter must be last")
+			return ast.FieldList{}, err
 		}
-
-		if c.current().Literal == "," {
-			c.advance(1)
+		e.span = c.span(e.start)
+		entries = append(entries, e)
+		if c.current().Literal != "," {
+			break
 		}
+		c.advance(1)
 	}
 	_, err = c.expectLiteral(")")
 	if err != nil {
-		return nil, err
+		return ast.FieldList{}, err
 	}
-	return args, nil
-}
 
-func parseFunctionReturnTypeDeclaration(c context) string {
-	retType := ""
-	if !c.eof() && c.current().Literal != "{" {
-		tok := c.current()
-		if tok.Kind == token.Type || tok.Kind == token.Identifier {
-			retType = tok.Literal
-			c.advance(1)
+	list := ast.FieldList{Span: c.span(lparen)}
+	var pending []entry
+	for i, e := range entries {
+		if e.variadic {
+			switch {
+			case !variadicOk:
+				return ast.FieldList{}, errorAt(*e.start.Position, "cannot use ... in results")
+			case i != len(entries)-1 || len(pending) > 0:
+				return ast.FieldList{}, errorAt(*e.start.Position, "can only use ... with final parameter")
+			}
+		}
+		switch {
+		case !named:
+			list.Fields = append(list.Fields, ast.Field{Span: e.span, Type: e.typ, Variadic: e.variadic})
+		case e.name == "":
+			if _, ok := e.typ.(ast.NamedType); !ok || e.start.Kind != token.Identifier || e.variadic {
+				return ast.FieldList{}, errorAt(*e.start.Position, "mixed named and unnamed parameters")
+			}
+			pending = append(pending, e)
+		default:
+			field := ast.Field{Span: e.span, Type: e.typ, Variadic: e.variadic}
+			for _, p := range pending {
+				field.Names = append(field.Names, p.start.Literal)
+			}
+			if len(pending) > 0 {
+				field.From = pending[0].span.From
+			}
+			field.Names = append(field.Names, e.name)
+			pending = nil
+			list.Fields = append(list.Fields, field)
 		}
 	}
-	return retType
+	if len(pending) > 0 {
+		return ast.FieldList{}, errorAt(*entries[len(entries)-1].start.Position, "mixed named and unnamed parameters")
+	}
+	return list, nil
 }
 
 func parseFunctionBodyDeclaration(c context) ([]ast.Node, error) {
@@ -358,16 +1680,9 @@ func parseFunctionBodyDeclaration(c context) ([]ast.Node, error) {
 		return nil, err
 	}
 
-	var body []ast.Node
-	for !c.eof() && c.current().Literal != "}" {
-		stmt, err := parseDeclaration(c)
-		if err != nil {
-			stmt, _, err = parseExpression(c, 0)
-			if err != nil {
-				return nil, err
-			}
-		}
-		body = append(body, stmt)
+	body, err := parseStatementList(c)
+	if err != nil {
+		return nil, err
 	}
 
 	_, err = c.expectLiteral("}")
@@ -378,44 +1693,94 @@ func parseFunctionBodyDeclaration(c context) ([]ast.Node, error) {
 	return body, nil
 }
 
+// parseStatementList parses statements until the end of the block or case clause.
+// The statements that fail to parse are recorded as errors and replaced with ast.BadStmt.
+func parseStatementList(c context) ([]ast.Node, error) {
+	var list []ast.Node
+	for !c.eof() {
+		t := c.current()
+		if t.Kind == token.Eof || t.Literal == "}" || t.Literal == "case" || t.Literal == "default" {
+			break
+		}
+		if t.Literal == ";" {
+			c.advance(1)
+			continue
+		}
+		lev := c.exprLevel()
+		stmt, err := parseStatement(c)
+		if err != nil {
+			c.recoverFrom(t, lev, err)
+			list = append(list, ast.BadStmt{Span: c.span(t)})
+			continue
+		}
+		if c.current() == t {
+			// Nothing is parsed, like at the stray ")".
+			c.recoverFrom(t, lev, errorAt(*t.Position, "unexpected %q", t.Literal))
+			list = append(list, ast.BadStmt{Span: c.span(t)})
+			continue
+		}
+		list = append(list, stmt)
+		if err := c.expectTerminator(); err != nil {
+			c.recoverFrom(t, lev, err)
+		}
+	}
+	return list, nil
+}
+
 func parseFunctionValue(c context) (ast.Node, error) {
-	_, err := c.expectLiteral("func")
+	start, err := c.expectLiteral("func")
 	if err != nil {
 		return nil, err
 	}
+	r := c.resolver()
+	r.openFunction()
+	defer r.closeFunction()
 
 	args, err := parseFunctionArgumentsDeclaration(c)
 	if err != nil {
 		return nil, err
 	}
 
-	retType := parseFunctionReturnTypeDeclaration(c)
+	results, err := parseFunctionResultsDeclaration(c)
+	if err != nil {
+		return nil, err
+	}
 
+	r.declare(true, fieldNames(args)...)
+	r.declare(true, fieldNames(results)...)
+	lev := c.exprLevel()
+	c.setExprLevel(lev + 1)
 	body, err := parseFunctionBodyDeclaration(c)
 	if err != nil {
 		return nil, err
 	}
+	c.setExprLevel(lev)
 
 	return ast.FunctionValue{
-		Arguments:  args,
-		ReturnType: retType,
-		Body:       body,
+		Span:      c.span(start),
+		Arguments: args,
+		Results:   results,
+		Body:      body,
+		Captures:  r.captures(),
 	}, nil
 }
 
 func parseTypeConversion(c context) (ast.Node, error) {
-	toToken, err := c.expectKind(token.Type)
+	start := c.current()
+	to, err := parseType(c)
 	if err != nil {
 		return nil, err
 	}
-	to := toToken.Literal
+	return parseTypeConversionTo(c, start, to)
+}
 
-	_, err = c.expectLiteral("(")
+func parseTypeConversionTo(c context, start *token.Token, to ast.TypeExpr) (ast.Node, error) {
+	_, err := c.expectLiteral("(")
 	if err != nil {
 		return nil, err
 	}
 
-	val, _, err := parseValue(c)
+	val, err := parseExpression(c, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -426,6 +1791,7 @@ func parseTypeConversion(c context) (ast.Node, error) {
 	}
 
 	return ast.TypeConversion{
+		Span:  c.span(start),
 		To:    to,
 		Value: val,
 	}, nil
@@ -15,6 +15,11 @@ import (
 type Parser struct {
 	tokens  []*token.Token
 	pos     int
+	exprLev int  // < 0: in a control clause, >= 0: in an expression
+	guard   bool // In the header of a switch statement, where ".(type)" is allowed
+	r       resolver
+	errors  ErrorList
+	limit   int
 	parsing bool
 	d       debug
 	mini    []mini
@@ -34,10 +39,13 @@ type debug struct {
 
 // New returns a pointer to Parser with the debug automatically turned off.
 // Notice that the debug writer is nil.
+//
+// The comments, which the scanner returns in scanner.ScanComments mode, are skipped.
 func New(tokens []*token.Token) (*Parser, error) {
 	p := &Parser{
-		tokens:  tokens,
+		tokens:  withoutComments(tokens),
 		pos:     0,
+		limit:   DefaultErrorLimit,
 		parsing: false,
 		mini:    miniParsers,
 	}
@@ -49,8 +57,9 @@ func New(tokens []*token.Token) (*Parser, error) {
 // w writer is used by the debugger.
 func NewDebug(tokens []*token.Token, w io.Writer) (*Parser, error) {
 	p := &Parser{
-		tokens:  tokens,
+		tokens:  withoutComments(tokens),
 		pos:     0,
+		limit:   DefaultErrorLimit,
 		parsing: false,
 		mini:    miniParsers,
 	}
@@ -74,12 +83,40 @@ func (p *Parser) SetTokens(t []*token.Token) error {
 	if t == nil {
 		return errors.New("parser: given tokens slice is nil")
 	}
-	p.tokens = t
+	p.tokens = withoutComments(t)
+	return nil
+}
+
+// withoutComments returns the tokens without token.Comment ones.
+func withoutComments(tokens []*token.Token) []*token.Token {
+	if !slices.ContainsFunc(tokens, func(t *token.Token) bool { return t.Kind == token.Comment }) {
+		return tokens
+	}
+	return slices.DeleteFunc(slices.Clone(tokens), func(t *token.Token) bool {
+		return t.Kind == token.Comment
+	})
+}
+
+// SetErrorLimit sets the maximum number of errors Parse reports.
+// If n is 0 or negative, all errors are reported.
+// If the parser is currently working, the function returns ErrWorking.
+func (p *Parser) SetErrorLimit(n int) error {
+	if p.parsing {
+		return ErrWorking
+	}
+	p.limit = n
 	return nil
 }
 
 // Parse parses the given tokens.
-// Returns ast.File node with the set statements.
+// Returns ast.File node with the package name, imports and set statements.
+//
+// The file must start with the package clause,
+// and the imports must precede other declarations.
+//
+// Parse doesn't stop at the first syntax error. It skips the rest of the broken
+// statement or declaration, puts ast.BadStmt in its place, and continues with the next one.
+// The errors are returned as ErrorList, along with the partially parsed file.
 func (p *Parser) Parse() (ast.File, error) {
 	p.d.p.parsing = true
 	p.debug("starting parsing")
@@ -88,7 +125,35 @@ func (p *Parser) Parse() (ast.File, error) {
 		p.debug("ending parsing")
 	}()
 
+	p.errors = nil
+	start := p.current()
 	f := ast.File{}
+	pkg, err := parsePackageClause(p)
+	if err != nil {
+		// Skip to the first declaration, so the rest of the file is still parsed.
+		p.addError(err)
+		for t := p.current(); t.Kind != token.Eof && !isDeclarationStart(t); t = p.current() {
+			p.advance(1)
+		}
+	} else {
+		f.Package = pkg
+		if err := p.expectTerminator(); err != nil {
+			p.recoverFrom(start, 0, err)
+		}
+	}
+	for p.current().Literal == "import" {
+		t := p.current()
+		specs, err := parseImportDeclaration(p)
+		if err == nil {
+			err = p.expectTerminator()
+		}
+		f.Imports = append(f.Imports, specs...)
+		if err != nil {
+			p.recoverFrom(t, 0, err)
+		}
+	}
+
+declarations:
 	for !p.eof() {
 		t := p.current()
 		if t.Kind == token.Eof {
@@ -97,13 +162,20 @@ func (p *Parser) Parse() (ast.File, error) {
 		for _, mini := range p.mini {
 			r, err := mini(p)
 			if err != nil {
-				return ast.File{}, err
+				p.recoverFrom(t, 0, err)
+				f.Statements = append(f.Statements, ast.BadStmt{Span: p.span(t)})
+				continue declarations
 			}
 			f.Statements = append(f.Statements, r)
 			p.debugf("parsed node at %v", p.pos)
 		}
+		if err := p.expectTerminator(); err != nil {
+			p.recoverFrom(t, 0, err)
+		}
 	}
-	return f, nil
+	f.Span = p.span(start)
+	p.errors.RemoveMultiples()
+	return f, p.errors.Err()
 }
 
 func (p *Parser) advance(n int) error {
@@ -199,6 +271,139 @@ func (p *Parser) expectKinds(kinds ...token.Kind) (*token.Token, error) {
 	}
 }
 
+// expectTerminator expects the statement to be terminated with ";".
+// Like in Go, the terminator may be omitted before a closing ")" or "}",
+// and at the end of the file.
+func (p *Parser) expectTerminator() error {
+	t := p.current()
+	if t.Kind == token.Eof || (t.Kind == token.Separator && (t.Literal == ")" || t.Literal == "}")) {
+		return nil
+	}
+	p.debug("expect statement terminator...")
+	_, err := p.expectLiteral(";")
+	return err
+}
+
+func (p *Parser) exprLevel() int {
+	return p.exprLev
+}
+
+func (p *Parser) setExprLevel(lev int) {
+	p.exprLev = lev
+}
+
+func (p *Parser) inSwitchHeader() bool {
+	return p.guard
+}
+
+func (p *Parser) setSwitchHeader(b bool) {
+	p.guard = b
+}
+
+func (p *Parser) resolver() *resolver {
+	return &p.r
+}
+
+// addError records the syntax error.
+// Like in Go, only the first error on the line is recorded,
+// as the others are usually caused by it.
+// The errors beyond the limit are dropped.
+func (p *Parser) addError(err error) {
+	var e *Error
+	var expect *expectError
+	switch {
+	case errors.As(err, &e):
+	case errors.As(err, &expect):
+		e = &Error{Pos: *expect.got.Position, Err: err}
+	default:
+		// The error has no position, like "unexpected EOF",
+		// so it's reported at the token the parser stopped at.
+		e = &Error{Pos: *p.current().Position, Err: err}
+	}
+	if n := len(p.errors); n > 0 && p.errors[n-1].Pos.Line == e.Pos.Line {
+		return
+	}
+	if p.limit > 0 && len(p.errors) >= p.limit {
+		return
+	}
+	p.errors = append(p.errors, e)
+}
+
+// recoverFrom records err and skips the rest of the statement or declaration
+// that starts at start, so the parser can continue with the next one.
+// lev is the expression level the statement is parsed at.
+//
+// The tokens are skipped up to and including ";" that isn't inside the braces
+// or parentheses opened by the statement, up to "}" that closes the enclosing block,
+// or up to ")" that closes the enclosing group, like "var (...)".
+//
+// So an unclosed brace doesn't swallow the rest of the file, the skipping
+// also stops at the first column, where gofmt-style code starts the declarations:
+// at any token if the statement is indented inside a function, and at keywords otherwise.
+// The rule depends on that indentation, so unformatted code may recover worse.
+func (p *Parser) recoverFrom(start *token.Token, lev int, err error) {
+	p.addError(err)
+	p.exprLev = lev
+	p.debugf("recovering from error: %v", err)
+
+	first := p.pos
+	for first > 0 && p.tokens[first] != start {
+		first--
+	}
+	braces, parens := 0, 0
+	for _, t := range p.tokens[first:p.pos] {
+		b, pa := depth(t)
+		braces, parens = braces+b, parens+pa
+	}
+	for t := p.current(); t.Kind != token.Eof; t = p.current() {
+		if p.pos > first && ((t.Literal == "}" && braces <= 0) || (t.Literal == ")" && braces <= 0 && parens <= 0) ||
+			(t.Position.Column == 1 && (start.Position.Column > 1 || t.Kind == token.Keyword))) {
+			return
+		}
+		b, pa := depth(t)
+		braces, parens = braces+b, parens+pa
+		p.advance(1)
+		if t.Literal == ";" && braces <= 0 && parens <= 0 {
+			return
+		}
+	}
+}
+
+// isDeclarationStart reports whether t is the keyword that starts a declaration.
+func isDeclarationStart(t *token.Token) bool {
+	return t.Kind == token.Keyword && slices.Contains([]string{"import", "func", "var", "const", "type"}, t.Literal)
+}
+
+// depth returns how t changes the depth of the braces and parentheses:
+// 1 if it opens them, -1 if it closes them, and 0 otherwise.
+func depth(t *token.Token) (braces, parens int) {
+	if t.Kind != token.Separator {
+		return 0, 0
+	}
+	switch t.Literal {
+	case "{":
+		return 1, 0
+	case "}":
+		return -1, 0
+	case "(":
+		return 0, 1
+	case ")":
+		return 0, -1
+	}
+	return 0, 0
+}
+
+// span returns the span from the start token to the end of the last parsed token.
+func (p *Parser) span(start *token.Token) ast.Span {
+	s := ast.Span{From: *start.Position, To: *start.Position}
+	if p.pos > 0 {
+		if last := p.tokens[p.pos-1]; last.End != nil && last.Position.Position >= start.Position.Position {
+			s.To = *last.End
+		}
+	}
+	return s
+}
+
 func (p *Parser) peek(n int) *token.Token {
 	if p.pos+n >= len(p.tokens) {
 		return nil
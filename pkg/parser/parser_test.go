@@ -0,0 +1,470 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dywoq/minigo/pkg/ast"
+	"github.com/dywoq/minigo/pkg/scanner"
+	"github.com/dywoq/minigo/pkg/token"
+)
+
+// parse scans and parses the source.
+func parse(t *testing.T, src string) (ast.File, error) {
+	t.Helper()
+	s, err := scanner.New(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := s.Scan()
+	if err != nil {
+		t.Fatalf("scanning %q: %v", src, err)
+	}
+	p, err := New(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.Parse()
+}
+
+// parseBody parses the statements as the body of the function.
+func parseBody(t *testing.T, body string) []ast.Node {
+	t.Helper()
+	f, err := parse(t, "package main\nfunc main() {\n"+body+"\n}\n")
+	if err != nil {
+		t.Fatalf("parsing %q: %v", body, err)
+	}
+	return f.Statements[0].(ast.Function).Body
+}
+
+func TestParseBareTypeArgument(t *testing.T) {
+	tests := []struct {
+		body string
+		typ  ast.TypeExpr
+	}{
+		{"ch := make(chan int, 1)", ast.ChanType{Direction: ast.ChanBoth, Elem: ast.NamedType{Name: "int"}}},
+		{"ch := make(chan<- int)", ast.ChanType{Direction: ast.ChanSend, Elem: ast.NamedType{Name: "int"}}},
+		{"ch := make(<-chan int)", ast.ChanType{Direction: ast.ChanReceive, Elem: ast.NamedType{Name: "int"}}},
+		{"xs := make([]int, n)", ast.SliceType{Elem: ast.NamedType{Name: "int"}}},
+		{"m := make(map[string]int)", ast.MapType{Key: ast.NamedType{Name: "string"}, Value: ast.NamedType{Name: "int"}}},
+	}
+	for _, test := range tests {
+		body := parseBody(t, test.body)
+		call, ok := body[0].(ast.Variable).Value.(ast.Call)
+		if !ok {
+			t.Errorf("%q: value is %T, want ast.Call", test.body, body[0].(ast.Variable).Value)
+			continue
+		}
+		if got, ok := call.Arguments[0].Value.(ast.TypeExpr); !ok || !sameType(got, test.typ) {
+			t.Errorf("%q: first argument is %#v, want %#v", test.body, call.Arguments[0].Value, test.typ)
+		}
+	}
+}
+
+func TestParseChannelSend(t *testing.T) {
+	body := parseBody(t, "ch := make(chan int, 1); ch <- 1")
+	if len(body) != 2 {
+		t.Fatalf("got %d statements, want 2", len(body))
+	}
+	if _, ok := body[1].(ast.SendStatement); !ok {
+		t.Errorf("second statement is %T, want ast.SendStatement", body[1])
+	}
+}
+
+func TestParseValueSpecType(t *testing.T) {
+	tests := []struct {
+		src string
+		typ ast.TypeExpr
+	}{
+		{"var a, b = 1, 2", ast.NamedType{Name: "int"}},
+		{"var c, d = 1, 2.5", nil},
+		{"var e, f = []int{1}, []int{2}", ast.SliceType{Elem: ast.NamedType{Name: "int"}}},
+		{"var g, h = []int{1}, []string{\"a\"}", nil},
+		{"var p, q = &Point{}, &Point{}", ast.PointerType{Elem: ast.NamedType{Name: "Point"}}},
+	}
+	for _, test := range tests {
+		f, err := parse(t, "package main\n"+test.src+"\n")
+		if err != nil {
+			t.Errorf("%q: %v", test.src, err)
+			continue
+		}
+		spec := f.Statements[0].(ast.Declaration).Specs[0]
+		if !sameType(spec.Type, test.typ) {
+			t.Errorf("%q: type is %#v, want %#v", test.src, spec.Type, test.typ)
+		}
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		body string
+		pos  string
+	}{
+		{"switch x {\ncase 1:\n\tfallthrough\n}", "5:2"},
+		{"select {\ndefault:\ndefault:\n}", "5:1"},
+		{"defer x", "3:1"},
+		{"x := )", "3:6"},
+		{"a, b += 1, 2", "3:6"},
+	}
+	for _, test := range tests {
+		_, err := parse(t, "package main\nfunc main() {\n"+test.body+"\n}\n")
+		list, ok := err.(ErrorList)
+		if !ok || len(list) != 1 {
+			t.Errorf("%q: got error %v, want one error", test.body, err)
+			continue
+		}
+		if got := list[0].Pos.String(); got != test.pos {
+			t.Errorf("%q: error %q is at %s, want %s", test.body, list[0], got, test.pos)
+		}
+	}
+}
+
+func TestParseBadPackageClause(t *testing.T) {
+	tests := []string{
+		"pakage main\nimport \"fmt\"\nfunc main() {}\n",
+		"import \"fmt\"\nfunc main() {}\n",
+	}
+	for _, src := range tests {
+		f, err := parse(t, src)
+		if list, ok := err.(ErrorList); !ok || len(list) != 1 {
+			t.Errorf("%q: got error %v, want one error", src, err)
+		}
+		if len(f.Imports) != 1 || len(f.Statements) != 1 {
+			t.Errorf("%q: got %d imports and %d statements, want 1 and 1", src, len(f.Imports), len(f.Statements))
+		}
+	}
+}
+
+func TestParseRecovery(t *testing.T) {
+	tests := []struct {
+		src        string
+		errors     int
+		statements []string // The types of the parsed statements
+	}{
+		{
+			"package main\nvar (\n\ta = 1\n\tb = 1 2\n\tc = 3\n)\nconst (\n\td = 1 +\n)\nfunc main() {}\n",
+			2, []string{"ast.Declaration", "ast.Declaration", "ast.Function"},
+		},
+		{
+			"package main\nimport (\n\t\"fmt\"\n\tfmt\n\t\"os\"\n)\nfunc main() {}\n",
+			1, []string{"ast.Function"},
+		},
+		{
+			"package main\nfunc f(x int { return x }\nfunc main() {}\n",
+			1, []string{"ast.BadStmt", "ast.Function"},
+		},
+		{
+			"package main\n)\nvar x = 1\n",
+			1, []string{"ast.BadStmt", "ast.Declaration"},
+		},
+		{
+			"package main\nfunc main() {\n\ta := 1\n\tf(a, b\n\tc := 2\n}\nvar x = 1\n",
+			1, []string{"ast.Function", "ast.Declaration"},
+		},
+		{
+			"package main\nfunc main() {\n\tx := []int{1, 2\n\ty := 3\n}\nvar z = 1\n",
+			1, []string{"ast.Function", "ast.Declaration"},
+		},
+		{
+			"package main\nfunc main() {\n\tif a b {\n\t\tc := 2\n\t}\n\td := 3\n}\n",
+			1, []string{"ast.Function"},
+		},
+	}
+	for _, test := range tests {
+		f, err := parse(t, test.src)
+		list, _ := err.(ErrorList)
+		if len(list) != test.errors {
+			t.Errorf("%q: got errors %v, want %d", test.src, err, test.errors)
+		}
+		var statements []string
+		for _, s := range f.Statements {
+			statements = append(statements, reflect.TypeOf(s).String())
+		}
+		if !slices.Equal(statements, test.statements) {
+			t.Errorf("%q: got statements %v, want %v", test.src, statements, test.statements)
+		}
+	}
+}
+
+func TestParseWithComments(t *testing.T) {
+	src := "// Package main.\npackage main\n\n/* The entry. */\nfunc main() {\n\tx := 1 // x\n\tx++\n}\n"
+	s, err := scanner.New(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetMode(scanner.ScanComments)
+	tokens, err := s.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := New(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := f.Statements[0].(ast.Function).Body; len(body) != 2 {
+		t.Errorf("got %d statements in main, want 2", len(body))
+	}
+}
+
+func TestParseTypeSwitchGuard(t *testing.T) {
+	tests := []struct {
+		body string
+		err  bool
+	}{
+		{"switch x.(type) {\n}", false},
+		{"switch v := x.(type) {\ncase int:\n\tv++\n}", false},
+		{"switch y := f(); v := y.(type) {\n}", false},
+		{"x := y.(type)", true},
+		{"f(y.(type))", true},
+		{"switch f(x.(type)) {\n}", true},
+		{"switch x.(type) + 1 {\n}", true},
+		{"switch x {\ncase 1:\n\ty := x.(type)\n}", true},
+	}
+	for _, test := range tests {
+		_, err := parse(t, "package main\nfunc main() {\n"+test.body+"\n}\n")
+		if (err != nil) != test.err {
+			t.Errorf("%q: got error %v, want error: %v", test.body, err, test.err)
+		}
+	}
+}
+
+func TestParseAssignmentMismatch(t *testing.T) {
+	tests := []struct {
+		body string
+		err  bool
+	}{
+		{"a, b := 1, 2", false},
+		{"a, b := f()", false},
+		{"a, b = m[k]", false},
+		{"v, ok := x.(int)", false},
+		{"a, b := 1", true},
+		{"a, b = 1", true},
+		{"a = 1, 2", true},
+		{"a, b = 1, 2, 3", true},
+		{"a, b = f(), g()", false},
+	}
+	for _, test := range tests {
+		_, err := parse(t, "package main\nfunc main() {\n"+test.body+"\n}\n")
+		if (err != nil) != test.err {
+			t.Errorf("%q: got error %v, want error: %v", test.body, err, test.err)
+		}
+	}
+	for _, src := range []string{"var a, b = 1", "a, b := 1"} {
+		_, err := parse(t, "package main\nfunc main() {\n"+src+"\n}\n")
+		if err == nil || !strings.Contains(err.Error(), "assignment mismatch: 2 variables but 1 value") {
+			t.Errorf("%q: got error %v, want assignment mismatch", src, err)
+		}
+	}
+}
+
+// syntaxTest is the source and the part of the error message it's parsed with,
+// or an empty string if it's valid.
+type syntaxTest struct {
+	src string
+	err string
+}
+
+// testSyntax parses each source, wrapped with the format, like "package main\n%s\n",
+// and checks the error.
+func testSyntax(t *testing.T, format string, tests []syntaxTest) {
+	t.Helper()
+	for _, test := range tests {
+		_, err := parse(t, fmt.Sprintf(format, test.src))
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%q: unexpected error %v", test.src, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%q: got error %v, want %q", test.src, err, test.err)
+		}
+	}
+}
+
+func TestParseSwitchErrors(t *testing.T) {
+	testSyntax(t, "package main\nfunc main() {\n%s\n}\n", []syntaxTest{
+		{"switch x {\ncase 1:\n\tfallthrough\ncase 2:\n}", ""},
+		{"switch x {\ndefault:\ncase 1:\n}", ""},
+		{"switch x {\ndefault:\ndefault:\n}", "multiple defaults in switch"},
+		{"switch x.(type) {\ndefault:\ndefault:\n}", "multiple defaults in switch"},
+		{"switch x {\ncase 1:\n\tfallthrough\n}", "cannot fallthrough final case in switch"},
+		{"switch x {\ncase 1:\n\tfallthrough\n\tf()\ncase 2:\n}", "fallthrough statement out of place"},
+		{"switch x.(type) {\ncase int:\n\tfallthrough\ncase string:\n}", "cannot fallthrough in type switch"},
+		{"switch x := f(); x {\ncase 1, 2:\n}", ""},
+		{"switch {\ncase x > 1:\n}", ""},
+	})
+}
+
+func TestParseStructType(t *testing.T) {
+	f, err := parse(t, "package main\ntype T struct {\n\tX, Y int `json:\"x\"`\n\tname string\n\tfmt.Stringer\n\t*Base \"base\"\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	named := func(name string) ast.TypeExpr { return ast.NamedType{Name: name} }
+	want := []ast.Field{
+		{Names: []string{"X", "Y"}, Type: named("int"), Tag: `json:"x"`},
+		{Names: []string{"name"}, Type: named("string")},
+		{Type: ast.QualifiedType{Package: "fmt", Name: "Stringer"}},
+		{Type: ast.PointerType{Elem: named("Base")}, Tag: "base"},
+	}
+	got := f.Statements[0].(ast.TypeDeclaration).Type
+	if !sameType(got, ast.StructType{Fields: ast.FieldList{Fields: want}}) {
+		t.Errorf("got %#v", got)
+	}
+
+	testSyntax(t, "package main\n%s\n", []syntaxTest{
+		{"type T struct{}", ""},
+		{"type T struct { a, b int; c string }", ""},
+		{"type T = int", ""},
+		{"type T struct { *[]int }", "embedded field type must be a type name"},
+		{"type T struct { x int `a` `b` }", "expected one of"},
+		{"var p = Point{X: 1, Y: 2}", ""},
+		{"var xs = [][]int{{1}, {2, 3}}", ""},
+		{"var xs = [...]int{1, 2}", ""},
+	})
+}
+
+func TestParseInterfaceType(t *testing.T) {
+	f, err := parse(t, "package main\ntype Number interface {\n\tfmt.Stringer\n\t~int | ~float | MyInt\n\tAbs() Number\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	named := func(name string) ast.TypeExpr { return ast.NamedType{Name: name} }
+	want := ast.InterfaceType{Methods: ast.FieldList{Fields: []ast.Field{
+		{Type: ast.QualifiedType{Package: "fmt", Name: "Stringer"}},
+		{Type: ast.UnionType{Terms: []ast.TypeTerm{
+			{Tilde: true, Type: named("int")},
+			{Tilde: true, Type: named("float")},
+			{Type: named("MyInt")},
+		}}},
+		{Names: []string{"Abs"}, Type: ast.FuncType{Results: ast.FieldList{Fields: []ast.Field{{Type: named("Number")}}}}},
+	}}}
+	if got := f.Statements[0].(ast.TypeDeclaration).Type; !sameType(got, want) {
+		t.Errorf("got %#v", got)
+	}
+
+	testSyntax(t, "package main\n%s\n", []syntaxTest{
+		{"type E interface{}", ""},
+		{"type S interface { ~string }", ""},
+		{"type R interface { io.Reader; Close() error }", ""},
+		{"type N interface { int | float }", ""},
+		{"var x = ~1", "cannot use ~ outside of interface or type constraint"},
+		{"type I interface { Read(p []byte) (n int, err error) }", ""},
+	})
+}
+
+func TestParseReceiver(t *testing.T) {
+	named := func(name string) ast.TypeExpr { return ast.NamedType{Name: name} }
+	tests := []struct {
+		src      string
+		receiver ast.Field
+	}{
+		{"func (p Point) X() int { return p.x }", ast.Field{Names: []string{"p"}, Type: named("Point")}},
+		{"func (p *Point) Move() {}", ast.Field{Names: []string{"p"}, Type: ast.PointerType{Elem: named("Point")}}},
+		{"func (Point) String() string { return \"\" }", ast.Field{Type: named("Point")}},
+		{"func (s *Stack[T]) Push(v T) {}", ast.Field{Names: []string{"s"}, Type: ast.PointerType{
+			Elem: ast.InstantiatedType{Type: named("Stack"), Arguments: []ast.TypeExpr{named("T")}},
+		}}},
+	}
+	for _, test := range tests {
+		f, err := parse(t, "package main\n"+test.src+"\n")
+		if err != nil {
+			t.Errorf("%q: %v", test.src, err)
+			continue
+		}
+		fn := f.Statements[0].(ast.Function)
+		if fn.Receiver == nil || !sameFields(ast.FieldList{Fields: []ast.Field{*fn.Receiver}}, ast.FieldList{Fields: []ast.Field{test.receiver}}) {
+			t.Errorf("%q: receiver is %#v, want %#v", test.src, fn.Receiver, test.receiver)
+		}
+	}
+
+	testSyntax(t, "package main\n%s\n", []syntaxTest{
+		{"func (a, b Point) F() {}", "method has 2 receivers"},
+		{"func () F() {}", "method has 0 receivers"},
+		{"func (p []int) F() {}", "invalid receiver type"},
+		{"func (p **Point) F() {}", "invalid receiver type"},
+		{"func (p Point) F[T any]() {}", "methods cannot have type parameters"},
+	})
+}
+
+func TestParseTypeParamsOrArray(t *testing.T) {
+	named := func(name string) ast.TypeExpr { return ast.NamedType{Name: name} }
+	tests := []struct {
+		src    string
+		params []string // The names of the type parameters
+		typ    ast.TypeExpr
+	}{
+		{"type A [N]int", nil, ast.ArrayType{Length: ast.Value{Value: "N", Kind: token.Identifier}, Elem: named("int")}},
+		{"type A [4]int", nil, ast.ArrayType{Length: ast.Value{Value: "4", Kind: token.Integer}, Elem: named("int")}},
+		{"type S[T any] []T", []string{"T"}, ast.SliceType{Elem: named("T")}},
+		{"type P[K comparable, V any] map[K]V", []string{"K", "V"}, ast.MapType{Key: named("K"), Value: named("V")}},
+		{"type P[K, V any] struct{}", []string{"K", "V"}, ast.StructType{}},
+		{"type N[T ~int | ~float] T", []string{"T"}, named("T")},
+		{"type L[T interface{ Less(T) bool }] []T", []string{"T"}, ast.SliceType{Elem: named("T")}},
+	}
+	for _, test := range tests {
+		f, err := parse(t, "package main\n"+test.src+"\n")
+		if err != nil {
+			t.Errorf("%q: %v", test.src, err)
+			continue
+		}
+		decl := f.Statements[0].(ast.TypeDeclaration)
+		if got := fieldNames(decl.TypeParams); !slices.Equal(got, test.params) {
+			t.Errorf("%q: type parameters are %v, want %v", test.src, got, test.params)
+		}
+		if !sameType(decl.Type, test.typ) {
+			t.Errorf("%q: type is %#v, want %#v", test.src, decl.Type, test.typ)
+		}
+	}
+
+	testSyntax(t, "package main\n%s\n", []syntaxTest{
+		{"type S[] int", ""},
+		{"type S[N] int", ""},
+		{"func f[]() {}", "empty type parameter list"},
+		{"type S[T, U] int", "missing type constraint"},
+		{"type E struct {\n\tPair[int, string]\n\tbuf [4]byte\n}", ""},
+		{"var s Stack[int]", ""},
+		{"var p Pair[string, []int]", ""},
+	})
+}
+
+func TestParseIndexOrInstantiation(t *testing.T) {
+	tests := []struct {
+		value string
+		node  ast.Node
+	}{
+		{"xs[i]", ast.IndexExpr{}},
+		{"m[\"k\"]", ast.IndexExpr{}},
+		{"Stack[int]", ast.Instantiation{}},
+		{"Stack[T]", ast.IndexExpr{}},
+		{"Map[int, string]", ast.Instantiation{}},
+		{"Map[int, string](m)", ast.Call{}},
+		{"Map[[]int]", ast.Instantiation{}},
+		{"f[map[string]int]", ast.Instantiation{}},
+		{"xs[1:2]", ast.SliceExpr{}},
+		{"xs[:]", ast.SliceExpr{}},
+		{"xs[1:2:3]", ast.SliceExpr{}},
+	}
+	for _, test := range tests {
+		body := parseBody(t, "v := "+test.value)
+		if got := body[0].(ast.Variable).Value; reflect.TypeOf(got) != reflect.TypeOf(test.node) {
+			t.Errorf("%s: parsed as %T, want %T", test.value, got, test.node)
+		}
+	}
+
+	body := parseBody(t, "v := Map[int, string](m)")
+	fn := body[0].(ast.Variable).Value.(ast.Call).Function
+	if inst, ok := fn.(ast.Instantiation); !ok || len(inst.TypeArguments) != 2 {
+		t.Errorf("called function is %#v, want the instantiation with 2 type arguments", fn)
+	}
+
+	testSyntax(t, "package main\nfunc main() {\n%s\n}\n", []syntaxTest{
+		{"v := xs[]", "expected operand"},
+		{"v := xs[1:2:]", "final index required in 3-index slice"},
+	})
+}
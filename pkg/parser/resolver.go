@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"slices"
+
+	"github.com/dywoq/minigo/pkg/ast"
+)
+
+// resolver tracks the local declarations while parsing,
+// so function values can record the variables they capture.
+// Names declared outside of functions are never captured, so they aren't tracked.
+type resolver struct {
+	scopes []map[string]bool // The name and whether it's a variable
+	funcs  []*funcScope
+}
+
+// funcScope is the scope of the function value.
+type funcScope struct {
+	depth    int // The index of the first scope of the function
+	captures []string
+}
+
+func (r *resolver) openScope() {
+	r.scopes = append(r.scopes, map[string]bool{})
+}
+
+func (r *resolver) closeScope() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+func (r *resolver) openFunction() {
+	r.funcs = append(r.funcs, &funcScope{depth: len(r.scopes)})
+	r.openScope()
+}
+
+func (r *resolver) closeFunction() {
+	r.closeScope()
+	r.funcs = r.funcs[:len(r.funcs)-1]
+}
+
+// captures returns the variables captured by the innermost function value.
+func (r *resolver) captures() []string {
+	return r.funcs[len(r.funcs)-1].captures
+}
+
+// declare declares the names in the innermost scope.
+// variable is false for constants, types and type parameters.
+func (r *resolver) declare(variable bool, names ...string) {
+	if len(r.scopes) == 0 {
+		return
+	}
+	for _, name := range names {
+		if name != "_" {
+			r.scopes[len(r.scopes)-1][name] = variable
+		}
+	}
+}
+
+// use resolves the name. If it's a variable declared outside of the function values
+// the name is used in, they capture it.
+func (r *resolver) use(name string) {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		variable, ok := r.scopes[i][name]
+		if !ok {
+			continue
+		}
+		if !variable {
+			return
+		}
+		for _, f := range r.funcs {
+			if f.depth > i && !slices.Contains(f.captures, name) {
+				f.captures = append(f.captures, name)
+			}
+		}
+		return
+	}
+}
+
+// fieldNames returns the names of all fields in the list.
+func fieldNames(l ast.FieldList) []string {
+	var names []string
+	for _, f := range l.Fields {
+		names = append(names, f.Names...)
+	}
+	return names
+}
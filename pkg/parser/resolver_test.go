@@ -0,0 +1,32 @@
+package parser
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/dywoq/minigo/pkg/ast"
+)
+
+func TestCaptures(t *testing.T) {
+	tests := []struct {
+		body     string
+		captures []string
+	}{
+		{"y := 0; f := func() int { return y }", []string{"y"}},
+		{"y := 0; f := func() int { y := 1; return y }", nil},
+		{"const y = 0; f := func() int { return y }", nil},
+		{"y := 0; f := func(y int) int { return y }", nil},
+		{"y := 0; f := func() Point { return Point{y: 1} }", nil},
+		{"y := 0; f := func() Point { return Point{X: y} }", []string{"y"}},
+		{"y := 0; f := func() map[int]int { return map[int]int{y: 1} }", []string{"y"}},
+		{"y := 0; f := func() map[int]int { return map[int]int{y + 1: 1} }", []string{"y"}},
+		{"y := 0; f := func() []int { return []int{y: 1} }", []string{"y"}},
+	}
+	for _, test := range tests {
+		body := parseBody(t, test.body)
+		fn := body[len(body)-1].(ast.Variable).Value.(ast.FunctionValue)
+		if !slices.Equal(fn.Captures, test.captures) {
+			t.Errorf("%q: captures are %v, want %v", test.body, fn.Captures, test.captures)
+		}
+	}
+}
@@ -0,0 +1,653 @@
+package parser
+
+import (
+	"slices"
+
+	"github.com/dywoq/minigo/pkg/ast"
+	"github.com/dywoq/minigo/pkg/token"
+)
+
+// isTypeStart reports whether t can start a type.
+func isTypeStart(t *token.Token) bool {
+	switch t.Kind {
+	case token.Type, token.Identifier:
+		return true
+	case token.Keyword:
+		return t.Literal == "map" || t.Literal == "func" || t.Literal == "chan" || t.Literal == "struct" || t.Literal == "interface"
+	case token.Separator, token.BinaryOperator, token.UnaryOperator:
+		return t.Literal == "*" || t.Literal == "[" || t.Literal == "(" || t.Literal == "<-"
+	}
+	return false
+}
+
+func parseType(c context) (ast.TypeExpr, error) {
+	t := c.current()
+	if !isTypeStart(t) {
+		return nil, errorAt(*t.Position, "expected type, got %q", t.Literal)
+	}
+	switch t.Literal {
+	case "*":
+		c.advance(1)
+		elem, err := parseType(c)
+		if err != nil {
+			return nil, err
+		}
+		return ast.PointerType{Span: c.span(t), Elem: elem}, nil
+
+	case "[":
+		return parseArrayOrSliceType(c)
+
+	case "map":
+		return parseMapType(c)
+
+	case "func":
+		c.advance(1)
+		args, err := parseFunctionArgumentsDeclaration(c)
+		if err != nil {
+			return nil, err
+		}
+		results, err := parseFunctionResultsDeclaration(c)
+		if err != nil {
+			return nil, err
+		}
+		return ast.FuncType{Span: c.span(t), Arguments: args, Results: results}, nil
+
+	case "chan", "<-":
+		return parseChanType(c)
+
+	case "struct":
+		return parseStructType(c)
+
+	case "interface":
+		return parseInterfaceType(c)
+
+	case "(":
+		c.advance(1)
+		typ, err := parseType(c)
+		if err != nil {
+			return nil, err
+		}
+		_, err = c.expectLiteral(")")
+		if err != nil {
+			return nil, err
+		}
+		return typ, nil
+	}
+
+	c.advance(1)
+	var typ ast.TypeExpr = ast.NamedType{Span: c.span(t), Name: t.Literal}
+	if next := c.peek(1); t.Kind == token.Identifier && c.current().Literal == "." && next != nil && next.Kind == token.Identifier {
+		c.advance(2)
+		typ = ast.QualifiedType{Span: c.span(t), Package: t.Literal, Name: next.Literal}
+	}
+	if t.Kind == token.Identifier && c.current().Literal == "[" {
+		return parseTypeArguments(c, t, typ)
+	}
+	return typ, nil
+}
+
+// parseTypeArguments parses the instantiation of the generic type, like "Pair[K, V]".
+// start is the first token of the type.
+func parseTypeArguments(c context, start *token.Token, typ ast.TypeExpr) (ast.TypeExpr, error) {
+	_, err := c.expectLiteral("[")
+	if err != nil {
+		return nil, err
+	}
+	inst := ast.InstantiatedType{Type: typ}
+	for {
+		arg, err := parseType(c)
+		if err != nil {
+			return nil, err
+		}
+		inst.Arguments = append(inst.Arguments, arg)
+		if c.current().Literal != "," {
+			break
+		}
+		c.advance(1)
+	}
+	_, err = c.expectLiteral("]")
+	if err != nil {
+		return nil, err
+	}
+	inst.Span = c.span(start)
+	return inst, nil
+}
+
+// isTypeParams reports whether the "[" after the name of the declared type
+// starts the type parameters, like in "type Stack[T any]",
+// rather than the length of the array type, like in "type Buffer [N]byte".
+func isTypeParams(c context) bool {
+	name, next := c.peek(1), c.peek(2)
+	if name == nil || next == nil || name.Kind != token.Identifier {
+		return false
+	}
+	return next.Literal == "," || next.Literal == "~" || (isTypeStart(next) && next.Literal != "*" && next.Literal != "(")
+}
+
+// parseTypeParams parses the type parameters, like "[K comparable, V any]".
+// Consecutive parameters may share the constraint, like in "[T, U any]".
+func parseTypeParams(c context) (ast.FieldList, error) {
+	start, err := c.expectLiteral("[")
+	if err != nil {
+		return ast.FieldList{}, err
+	}
+	if c.current().Literal == "]" {
+		return ast.FieldList{}, errorAt(*start.Position, "empty type parameter list")
+	}
+	list := ast.FieldList{}
+	var names []string
+	var first *token.Token
+	for {
+		name, err := c.expectKind(token.Identifier)
+		if err != nil {
+			return ast.FieldList{}, err
+		}
+		if names == nil {
+			first = name
+		}
+		names = append(names, name.Literal)
+		if c.current().Literal == "," {
+			c.advance(1)
+			continue
+		}
+		if c.current().Literal == "]" {
+			return ast.FieldList{}, errorAt(*name.Position, "missing type constraint")
+		}
+		constraint, err := parseUnion(c)
+		if err != nil {
+			return ast.FieldList{}, err
+		}
+		list.Fields = append(list.Fields, ast.Field{Span: c.span(first), Names: names, Type: constraint})
+		names = nil
+		if c.current().Literal != "," {
+			break
+		}
+		c.advance(1)
+		if c.current().Literal == "]" {
+			break
+		}
+	}
+	_, err = c.expectLiteral("]")
+	if err != nil {
+		return ast.FieldList{}, err
+	}
+	list.Span = c.span(start)
+	return list, nil
+}
+
+func parseArrayOrSliceType(c context) (ast.TypeExpr, error) {
+	start, err := c.expectLiteral("[")
+	if err != nil {
+		return nil, err
+	}
+	if c.current().Literal == "]" {
+		c.advance(1)
+		elem, err := parseType(c)
+		if err != nil {
+			return nil, err
+		}
+		return ast.SliceType{Span: c.span(start), Elem: elem}, nil
+	}
+
+	var length ast.Node
+	if c.current().Literal == "..." {
+		c.advance(1)
+	} else {
+		length, err = parseExpression(c, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = c.expectLiteral("]")
+	if err != nil {
+		return nil, err
+	}
+	elem, err := parseType(c)
+	if err != nil {
+		return nil, err
+	}
+	return ast.ArrayType{Span: c.span(start), Length: length, Elem: elem}, nil
+}
+
+func parseMapType(c context) (ast.TypeExpr, error) {
+	start, err := c.expectLiteral("map")
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.expectLiteral("[")
+	if err != nil {
+		return nil, err
+	}
+	key, err := parseType(c)
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.expectLiteral("]")
+	if err != nil {
+		return nil, err
+	}
+	value, err := parseType(c)
+	if err != nil {
+		return nil, err
+	}
+	return ast.MapType{Span: c.span(start), Key: key, Value: value}, nil
+}
+
+func parseChanType(c context) (ast.TypeExpr, error) {
+	start := c.current()
+	dir := ast.ChanBoth
+	if c.current().Literal == "<-" {
+		dir = ast.ChanReceive
+		c.advance(1)
+	}
+	_, err := c.expectLiteral("chan")
+	if err != nil {
+		return nil, err
+	}
+	if dir == ast.ChanBoth && c.current().Literal == "<-" {
+		dir = ast.ChanSend
+		c.advance(1)
+	}
+	elem, err := parseType(c)
+	if err != nil {
+		return nil, err
+	}
+	return ast.ChanType{Span: c.span(start), Direction: dir, Elem: elem}, nil
+}
+
+func parseStructType(c context) (ast.TypeExpr, error) {
+	start, err := c.expectLiteral("struct")
+	if err != nil {
+		return nil, err
+	}
+	lbrace, err := c.expectLiteral("{")
+	if err != nil {
+		return nil, err
+	}
+	list := ast.FieldList{}
+	for c.current().Literal != "}" && c.current().Kind != token.Eof {
+		field, err := parseStructField(c)
+		if err != nil {
+			return nil, err
+		}
+		list.Fields = append(list.Fields, field)
+		if err := c.expectTerminator(); err != nil {
+			return nil, err
+		}
+	}
+	_, err = c.expectLiteral("}")
+	if err != nil {
+		return nil, err
+	}
+	list.Span = c.span(lbrace)
+	return ast.StructType{Span: c.span(start), Fields: list}, nil
+}
+
+// parseStructField parses a named field, like "X, Y int",
+// or an embedded one, like "*fmt.Stringer", with the optional tag.
+func parseStructField(c context) (ast.Field, error) {
+	field := ast.Field{}
+	t := c.current()
+	next := c.peek(1)
+	embedded := t.Literal == "*" || (t.Kind == token.Identifier && next != nil &&
+		(next.Literal == "." || isFieldEnd(next) || (next.Literal == "[" && isEmbeddedInstance(c))))
+
+	if embedded {
+		typ, err := parseType(c)
+		if err != nil {
+			return ast.Field{}, err
+		}
+		name := typ
+		if p, ok := typ.(ast.PointerType); ok {
+			name = p.Elem
+		}
+		switch name.(type) {
+		case ast.NamedType, ast.QualifiedType, ast.InstantiatedType:
+		default:
+			return ast.Field{}, errorAt(*t.Position, "embedded field type must be a type name")
+		}
+		field.Type = typ
+	} else {
+		for {
+			name, err := c.expectKind(token.Identifier)
+			if err != nil {
+				return ast.Field{}, err
+			}
+			field.Names = append(field.Names, name.Literal)
+			if c.current().Literal != "," {
+				break
+			}
+			c.advance(1)
+		}
+		typ, err := parseType(c)
+		if err != nil {
+			return ast.Field{}, err
+		}
+		field.Type = typ
+	}
+
+	if tag := c.current(); tag.Kind == token.String || tag.Kind == token.RawString {
+		c.advance(1)
+		value, err := token.Unquote(tag.Literal)
+		if err != nil {
+			return ast.Field{}, err
+		}
+		field.Tag = value
+	}
+	field.Span = c.span(t)
+	return field, nil
+}
+
+// isFieldEnd reports whether t ends the struct field, or starts its tag.
+func isFieldEnd(t *token.Token) bool {
+	return t.Literal == ";" || t.Literal == "}" || t.Kind == token.String || t.Kind == token.RawString
+}
+
+// isEmbeddedInstance reports whether the name followed by "[" is the embedded instantiated type,
+// like "Pair[K, V]", rather than the field of the array type, like "buf [N]byte".
+// Unlike the array type, nothing follows the closing "]" of the type arguments.
+func isEmbeddedInstance(c context) bool {
+	depth := 0
+	for i := 1; ; i++ {
+		t := c.peek(i)
+		if t == nil || t.Kind == token.Eof {
+			return false
+		}
+		switch t.Literal {
+		case "[":
+			depth++
+		case "]":
+			depth--
+			if depth == 0 {
+				next := c.peek(i + 1)
+				return next != nil && isFieldEnd(next)
+			}
+		}
+	}
+}
+
+func parseInterfaceType(c context) (ast.TypeExpr, error) {
+	start, err := c.expectLiteral("interface")
+	if err != nil {
+		return nil, err
+	}
+	lbrace, err := c.expectLiteral("{")
+	if err != nil {
+		return nil, err
+	}
+	list := ast.FieldList{}
+	for c.current().Literal != "}" && c.current().Kind != token.Eof {
+		field, err := parseInterfaceElement(c)
+		if err != nil {
+			return nil, err
+		}
+		list.Fields = append(list.Fields, field)
+		if err := c.expectTerminator(); err != nil {
+			return nil, err
+		}
+	}
+	_, err = c.expectLiteral("}")
+	if err != nil {
+		return nil, err
+	}
+	list.Span = c.span(lbrace)
+	return ast.InterfaceType{Span: c.span(start), Methods: list}, nil
+}
+
+// parseInterfaceElement parses a method, like "Area() float",
+// or an embedded element, like "fmt.Stringer" or "~int | ~float".
+func parseInterfaceElement(c context) (ast.Field, error) {
+	t := c.current()
+	if next := c.peek(1); t.Kind == token.Identifier && next != nil && next.Literal == "(" {
+		c.advance(1)
+		lparen := c.current()
+		args, err := parseFunctionArgumentsDeclaration(c)
+		if err != nil {
+			return ast.Field{}, err
+		}
+		results, err := parseFunctionResultsDeclaration(c)
+		if err != nil {
+			return ast.Field{}, err
+		}
+		return ast.Field{
+			Span:  c.span(t),
+			Names: []string{t.Literal},
+			Type:  ast.FuncType{Span: c.span(lparen), Arguments: args, Results: results},
+		}, nil
+	}
+
+	typ, err := parseUnion(c)
+	if err != nil {
+		return ast.Field{}, err
+	}
+	return ast.Field{Span: c.span(t), Type: typ}, nil
+}
+
+// parseUnion parses the union of type terms, like "~int | ~float".
+// A single term without the tilde is returned as is.
+func parseUnion(c context) (ast.TypeExpr, error) {
+	start := c.current()
+	union := ast.UnionType{}
+	for {
+		term := ast.TypeTerm{}
+		if c.current().Literal == "~" {
+			term.Tilde = true
+			c.advance(1)
+		}
+		typ, err := parseType(c)
+		if err != nil {
+			return nil, err
+		}
+		term.Type = typ
+		union.Terms = append(union.Terms, term)
+		if c.current().Literal != "|" {
+			break
+		}
+		c.advance(1)
+	}
+	if len(union.Terms) == 1 && !union.Terms[0].Tilde {
+		return union.Terms[0].Type, nil
+	}
+	union.Span = c.span(start)
+	return union, nil
+}
+
+// typeFromExpression converts the expression that names a type,
+// like "Point", "geo.Point" or "Stack[int]", to the type.
+func typeFromExpression(n ast.Node) (ast.TypeExpr, bool) {
+	switch v := n.(type) {
+	case ast.Value:
+		if v.Kind == token.Identifier {
+			return ast.NamedType{Span: v.Span, Name: v.Value}, true
+		}
+	case ast.SelectorExpr:
+		if pkg, ok := v.Operand.(ast.Value); ok && pkg.Kind == token.Identifier {
+			return ast.QualifiedType{Span: v.Span, Package: pkg.Value, Name: v.Selector}, true
+		}
+
+	case ast.IndexExpr:
+		typ, ok := typeFromExpression(v.Operand)
+		if !ok {
+			return nil, false
+		}
+		arg, ok := typeFromExpression(v.Index)
+		if !ok {
+			return nil, false
+		}
+		return ast.InstantiatedType{Span: v.Span, Type: typ, Arguments: []ast.TypeExpr{arg}}, true
+
+	case ast.Instantiation:
+		typ, ok := typeFromExpression(v.Operand)
+		if !ok {
+			return nil, false
+		}
+		return ast.InstantiatedType{Span: v.Span, Type: typ, Arguments: v.TypeArguments}, true
+	}
+	return nil, false
+}
+
+// sameType reports whether a and b are written the same way, ignoring their spans.
+// Array lengths are only compared if they're literals or names.
+func sameType(a, b ast.TypeExpr) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	switch a := a.(type) {
+	case ast.NamedType:
+		b, ok := b.(ast.NamedType)
+		return ok && a.Name == b.Name
+	case ast.QualifiedType:
+		b, ok := b.(ast.QualifiedType)
+		return ok && a.Package == b.Package && a.Name == b.Name
+	case ast.PointerType:
+		b, ok := b.(ast.PointerType)
+		return ok && sameType(a.Elem, b.Elem)
+	case ast.SliceType:
+		b, ok := b.(ast.SliceType)
+		return ok && sameType(a.Elem, b.Elem)
+	case ast.ArrayType:
+		b, ok := b.(ast.ArrayType)
+		if !ok || !sameType(a.Elem, b.Elem) {
+			return false
+		}
+		if a.Length == nil || b.Length == nil {
+			return a.Length == nil && b.Length == nil
+		}
+		al, aok := a.Length.(ast.Value)
+		bl, bok := b.Length.(ast.Value)
+		return aok && bok && al.Kind == bl.Kind && al.Value == bl.Value
+	case ast.MapType:
+		b, ok := b.(ast.MapType)
+		return ok && sameType(a.Key, b.Key) && sameType(a.Value, b.Value)
+	case ast.ChanType:
+		b, ok := b.(ast.ChanType)
+		return ok && a.Direction == b.Direction && sameType(a.Elem, b.Elem)
+	case ast.FuncType:
+		b, ok := b.(ast.FuncType)
+		return ok && sameFields(a.Arguments, b.Arguments) && sameFields(a.Results, b.Results)
+	case ast.StructType:
+		b, ok := b.(ast.StructType)
+		return ok && sameFields(a.Fields, b.Fields)
+	case ast.InterfaceType:
+		b, ok := b.(ast.InterfaceType)
+		return ok && sameFields(a.Methods, b.Methods)
+	case ast.UnionType:
+		b, ok := b.(ast.UnionType)
+		return ok && slices.EqualFunc(a.Terms, b.Terms, func(x, y ast.TypeTerm) bool {
+			return x.Tilde == y.Tilde && sameType(x.Type, y.Type)
+		})
+	case ast.InstantiatedType:
+		b, ok := b.(ast.InstantiatedType)
+		return ok && sameType(a.Type, b.Type) && slices.EqualFunc(a.Arguments, b.Arguments, sameType)
+	}
+	return false
+}
+
+// sameFields reports whether the field lists are written the same way, ignoring their spans.
+func sameFields(a, b ast.FieldList) bool {
+	return slices.EqualFunc(a.Fields, b.Fields, func(x, y ast.Field) bool {
+		return slices.Equal(x.Names, y.Names) && x.Variadic == y.Variadic && x.Tag == y.Tag && sameType(x.Type, y.Type)
+	})
+}
+
+// untypedKinds lists the names of the default types of untyped constants,
+// from the lowest to the highest kind. Like in Go, an operation on two untyped
+// constants results in the highest kind of them, so 1 + 2.5 is a float.
+var untypedKinds = []string{"int", "rune", "float"}
+
+// inferType returns the type of the value, if the value alone tells it.
+// Otherwise, it returns nil.
+func inferType(n ast.Node) ast.TypeExpr {
+	switch v := n.(type) {
+	case ast.Value:
+		switch v.Kind {
+		case token.Integer:
+			return ast.NamedType{Name: "int"}
+		case token.Float:
+			return ast.NamedType{Name: "float"}
+		case token.String, token.RawString:
+			return ast.NamedType{Name: "string"}
+		case token.Char:
+			return ast.NamedType{Name: "rune"}
+		case token.Identifier:
+			switch v.Value {
+			case "true", "false":
+				return ast.NamedType{Name: "bool"}
+			case "iota":
+				return ast.NamedType{Name: "int"}
+			}
+		}
+
+	case ast.TypeConversion:
+		return v.To
+
+	case ast.CompositeLiteral:
+		return v.Type
+
+	case ast.TypeAssertExpr:
+		return v.Type
+
+	case ast.FunctionValue:
+		return ast.FuncType{Arguments: v.Arguments, Results: v.Results}
+
+	case ast.UnaryExpression:
+		switch v.Operator {
+		case "!":
+			return ast.NamedType{Name: "bool"}
+		case "-", "+", "^":
+			return inferType(v.Operand)
+		case "&":
+			if elem := inferType(v.Operand); elem != nil {
+				return ast.PointerType{Elem: elem}
+			}
+		}
+
+	case ast.BinaryExpression:
+		switch v.Operator {
+		case "==", "!=", "<", "<=", ">", ">=", "&&", "||":
+			return ast.NamedType{Name: "bool"}
+		case "<<", ">>":
+			return inferType(v.Left)
+		}
+		left, right := inferType(v.Left), inferType(v.Right)
+		if left == nil || right == nil {
+			return nil
+		}
+		switch lu, ru := isUntyped(v.Left), isUntyped(v.Right); {
+		case lu && ru:
+			l, r := left.(ast.NamedType), right.(ast.NamedType)
+			if slices.Index(untypedKinds, r.Name) > slices.Index(untypedKinds, l.Name) {
+				return right
+			}
+		case lu:
+			// The untyped constant is converted to the type of the other side.
+			return right
+		}
+		return left
+	}
+	return nil
+}
+
+// isUntyped reports whether the value is an untyped numeric constant, like "1", "'a'" or "2.5 * iota".
+func isUntyped(n ast.Node) bool {
+	switch v := n.(type) {
+	case ast.Value:
+		switch v.Kind {
+		case token.Integer, token.Float, token.Char:
+			return true
+		case token.Identifier:
+			return v.Value == "iota"
+		}
+	case ast.UnaryExpression:
+		return (v.Operator == "-" || v.Operator == "+" || v.Operator == "^") && isUntyped(v.Operand)
+	case ast.BinaryExpression:
+		switch v.Operator {
+		case "==", "!=", "<", "<=", ">", ">=", "&&", "||":
+			return false
+		case "<<", ">>":
+			return isUntyped(v.Left)
+		}
+		return isUntyped(v.Left) && isUntyped(v.Right)
+	}
+	return false
+}
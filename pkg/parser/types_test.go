@@ -0,0 +1,52 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/dywoq/minigo/pkg/ast"
+)
+
+func TestInferType(t *testing.T) {
+	named := func(name string) ast.TypeExpr { return ast.NamedType{Name: name} }
+	tests := []struct {
+		value string
+		typ   ast.TypeExpr
+	}{
+		{"1", named("int")},
+		{"2.5", named("float")},
+		{"'a'", named("rune")},
+		{`"s"`, named("string")},
+		{"`raw`", named("string")},
+		{"true", named("bool")},
+		{"iota", named("int")},
+		{"x", nil},
+		{"-1", named("int")},
+		{"!x", named("bool")},
+		{"&Point{}", ast.PointerType{Elem: named("Point")}},
+		{"[]int{1}", ast.SliceType{Elem: named("int")}},
+		{"float(1)", named("float")},
+		{"x.(string)", named("string")},
+		{"x == 1", named("bool")},
+		{"1 + 2", named("int")},
+		{"1 + 2.5", named("float")},
+		{"'a' + 1", named("rune")},
+		{"1 << 2.0", named("int")},
+		{"x * 2", nil},
+		{"2 * x", nil},
+		{"MyInt(1) + 2", nil},
+		{"x.(MyInt) + 2", named("MyInt")},
+		{"2.5 + x.(MyInt)", named("MyInt")},
+		{"int(1) + 2.5", named("int")},
+		{"func(a int) bool { return true }", ast.FuncType{
+			Arguments: ast.FieldList{Fields: []ast.Field{{Names: []string{"a"}, Type: named("int")}}},
+			Results:   ast.FieldList{Fields: []ast.Field{{Type: named("bool")}}},
+		}},
+	}
+	for _, test := range tests {
+		body := parseBody(t, "v := "+test.value)
+		got := body[0].(ast.Variable).Type
+		if !sameType(got, test.typ) {
+			t.Errorf("%s: type is %#v, want %#v", test.value, got, test.typ)
+		}
+	}
+}
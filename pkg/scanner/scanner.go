@@ -4,8 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"slices"
+	"strings"
 	"time"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/dywoq/minigo/pkg/token"
 )
@@ -16,11 +19,23 @@ type Scanner struct {
 	input      []byte // used to prevent multiple using io.ReadAll
 	r          io.Reader
 	p          *token.Position
+	start      token.Position // the position of the token being tokenized
 	d          debug
+	mode       Mode
 	scanning   bool
+	insertSemi bool // whether a newline ends the statement
 	tokenizers []tokenizer
 }
 
+// Mode is a set of flags controlling the scanner behavior.
+type Mode uint
+
+const (
+	// ScanComments makes the scanner return comments as token.Comment tokens.
+	// By default, comments are skipped.
+	ScanComments Mode = 1 << iota
+)
+
 type debug struct {
 	s  *Scanner
 	w  io.Writer
@@ -32,13 +47,18 @@ type debug struct {
 var ErrWorking = errors.New("scanner: scanning right now")
 
 var defaultTokenizers = []tokenizer{
+	tokenizeComment,
+	tokenizeAssignOperator,
+	tokenizeIncDecOperator,
 	tokenizeBinaryOperator,
+	tokenizeUnaryOperator,
 	tokenizeKeyword,
 	tokenizeType,
 	tokenizeIdentifier,
 	tokenizeNumber,
 	tokenizeSeparator,
 	tokenizeString,
+	tokenizeChar,
 }
 
 // New returns a pointer to Scanner with the given io.Reader instance.
@@ -55,7 +75,10 @@ func New(r io.Reader) (*Scanner, error) {
 		bytes,
 		r,
 		token.NewPosition(1, 1, 0),
+		token.Position{},
 		debug{},
+		0,
+		false,
 		false,
 		defaultTokenizers,
 	}, nil
@@ -89,6 +112,21 @@ func (s *Scanner) Debug() debug {
 	return s.d
 }
 
+// Mode returns the current scanner mode.
+func (s *Scanner) Mode() Mode {
+	return s.mode
+}
+
+// SetMode sets the scanner mode.
+// Returns ErrWorking if the scanner is working right now.
+func (s *Scanner) SetMode(m Mode) error {
+	if s.scanning {
+		return ErrWorking
+	}
+	s.mode = m
+	return nil
+}
+
 // SetReader sets the reader and updates the underlying input.
 // Returns an error if scanner is already working,
 // or something went wrong when trying to get the content with io.ReadAll.
@@ -110,6 +148,12 @@ func (s *Scanner) SetReader(r io.Reader) error {
 // Scan scans the given input, and tokenizes it.
 // If the current character doesn't satisfy the requirements of one of tokenizers,
 // Scan tries other tokenizer.
+//
+// Like in Go, Scan automatically inserts ";" separators at the newlines
+// (and at the end of the input), if the line's last token is
+// an identifier, a type, a literal, one of the keywords
+// "return", "break", "continue" or "fallthrough", one of ")", "]" and "}",
+// or one of "++" and "--".
 func (s *Scanner) Scan() ([]*token.Token, error) {
 	result := []*token.Token{}
 	s.scanning = true
@@ -119,8 +163,19 @@ func (s *Scanner) Scan() ([]*token.Token, error) {
 		s.scanning = false
 	}()
 
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
 	for !s.eof() {
 		s.skipWhitespace()
+		s.start = *s.p
+		if r, _ := s.current(); r == '\n' && s.insertSemi {
+			s.debug("inserting semicolon at newline")
+			result = append(result, s.new(";", token.Separator))
+			s.insertSemi = false
+			s.advance(1)
+			continue
+		}
 		tok, err := s.tokenize()
 		if err != nil {
 			if err == io.EOF {
@@ -130,15 +185,78 @@ func (s *Scanner) Scan() ([]*token.Token, error) {
 		}
 		if tok.Kind == token.Illegal {
 			r, _ := s.current()
-			return nil, fmt.Errorf("met illegal character: %s", string(r))
+			return nil, fmt.Errorf("met illegal character: %s at %d:%d", string(r), s.p.Line, s.p.Column)
+		}
+		if tok.Kind == token.Comment {
+			// Like in Go, the semicolon is inserted before the comment that ends the line,
+			// so the comment comes after the statement it's written after.
+			if s.insertSemi && (strings.HasPrefix(tok.Literal, "//") || strings.Contains(tok.Literal, "\n")) {
+				s.debug("inserting semicolon before comment")
+				semi := s.new(";", token.Separator)
+				*semi.End = *semi.Position
+				result = append(result, semi)
+				s.insertSemi = false
+			}
+			if s.mode&ScanComments == 0 {
+				s.debug("skipping comment")
+				continue
+			}
+			result = append(result, tok)
+			continue
 		}
 		result = append(result, tok)
+		s.insertSemi = insertsSemicolon(tok)
 		s.debugf("tokenized: %s", tok.Literal)
 	}
-	result = append(result, token.NewToken("", token.Eof, s.p))
+	if s.insertSemi {
+		s.debug("inserting semicolon at the end")
+		s.start = *s.p
+		result = append(result, s.new(";", token.Separator))
+		s.insertSemi = false
+	}
+	eof := token.NewToken("", token.Eof, s.p)
+	eof.End = s.p
+	result = append(result, eof)
 	return result, nil
 }
 
+// insertsSemicolon reports whether a newline after tok ends the statement.
+func insertsSemicolon(tok *token.Token) bool {
+	switch tok.Kind {
+	case token.Identifier, token.Type, token.Integer, token.Float, token.String, token.RawString, token.Char, token.IncDecOperator:
+		return true
+	case token.Keyword:
+		return slices.Contains([]string{"return", "break", "continue", "fallthrough"}, tok.Literal)
+	case token.Separator:
+		return slices.Contains([]string{")", "]", "}"}, tok.Literal)
+	}
+	return false
+}
+
+// validate reports the position of the first invalid UTF-8 sequence in the input.
+// A leading byte order mark is skipped.
+func (s *Scanner) validate() error {
+	if s.p.Position == 0 && len(s.input) >= 3 && string(s.input[:3]) == "\uFEFF" {
+		s.debug("skipping byte order mark")
+		s.p.Position = 3
+	}
+	line, column := s.p.Line, s.p.Column
+	for i := s.p.Position; i < len(s.input); {
+		r, size := utf8.DecodeRune(s.input[i:])
+		if r == utf8.RuneError && size == 1 {
+			return fmt.Errorf("invalid UTF-8 encoding at %d:%d", line, column)
+		}
+		if r == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+		i += size
+	}
+	return nil
+}
+
 func (s *Scanner) tokenize() (*token.Token, error) {
 	for _, tokenizer := range s.tokenizers {
 		tok, err := tokenizer(s)
@@ -160,7 +278,7 @@ func (s *Scanner) skipWhitespace() {
 			break
 		}
 		r, _ := s.current()
-		if !unicode.IsSpace(r) {
+		if !unicode.IsSpace(r) || (r == '\n' && s.insertSemi) {
 			break
 		}
 		s.debug("skipping whitespace")
@@ -181,8 +299,10 @@ func (s *Scanner) slice(start, end int) (string, error) {
 }
 
 func (s *Scanner) new(literal string, kind token.Kind) *token.Token {
-	posCopy := *s.p
-	return token.NewToken(literal, kind, &posCopy)
+	posCopy, endCopy := s.start, *s.p
+	t := token.NewToken(literal, kind, &posCopy)
+	t.End = &endCopy
+	return t
 }
 
 func (s *Scanner) advance(n int) error {
@@ -193,8 +313,8 @@ func (s *Scanner) advance(n int) error {
 		if s.eof() {
 			return io.EOF
 		}
-		r := rune(s.input[s.p.Position])
-		s.p.Position++
+		r, size := utf8.DecodeRune(s.input[s.p.Position:])
+		s.p.Position += size
 		if r == '\n' {
 			s.p.Line++
 			s.p.Column = 1
@@ -217,15 +337,16 @@ func (s *Scanner) backwards(n int) error {
 	s.p.Position = newPos
 	s.p.Line = 1
 	s.p.Column = 1
-	for i := 0; i < s.p.Position; i++ {
-		r := rune(s.input[i])
-		s.debugf("moving backwards by %d", n)
+	s.debugf("moving backwards by %d", n)
+	for i := 0; i < s.p.Position; {
+		r, size := utf8.DecodeRune(s.input[i:])
 		if r == '\n' {
 			s.p.Line++
 			s.p.Column = 1
-		} else {
+		} else if r != '\uFEFF' || i != 0 {
 			s.p.Column++
 		}
+		i += size
 	}
 	return nil
 }
@@ -234,8 +355,12 @@ func (s *Scanner) current() (rune, error) {
 	if s.eof() {
 		return 0, io.EOF
 	}
-	s.debugf("getting current character: %s", string(s.input[s.p.Position]))
-	return rune(s.input[s.p.Position]), nil
+	r, size := utf8.DecodeRune(s.input[s.p.Position:])
+	if r == utf8.RuneError && size == 1 {
+		return 0, fmt.Errorf("invalid UTF-8 encoding at %d:%d", s.p.Line, s.p.Column)
+	}
+	s.debugf("getting current character: %s", string(r))
+	return r, nil
 }
 
 func (s *Scanner) eof() bool {
@@ -0,0 +1,215 @@
+package scanner
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dywoq/minigo/pkg/token"
+)
+
+// scan scans the source in the mode.
+func scan(t *testing.T, src string, mode Mode) ([]*token.Token, error) {
+	t.Helper()
+	s, err := New(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetMode(mode); err != nil {
+		t.Fatal(err)
+	}
+	return s.Scan()
+}
+
+// literals returns the literals of the tokens, without the final token.Eof.
+func literals(tokens []*token.Token) []string {
+	var list []string
+	for _, t := range tokens {
+		if t.Kind != token.Eof {
+			list = append(list, t.Literal)
+		}
+	}
+	return list
+}
+
+func TestScanComments(t *testing.T) {
+	tests := []struct {
+		src      string
+		mode     Mode
+		literals []string
+	}{
+		{"a // c\nb", 0, []string{"a", ";", "b", ";"}},
+		{"a // c\nb", ScanComments, []string{"a", ";", "// c", "b", ";"}},
+		{"a /* c */ b", 0, []string{"a", "b", ";"}},
+		{"a /* c\n */ b", 0, []string{"a", ";", "b", ";"}},
+		{"a /* c\n */ b", ScanComments, []string{"a", ";", "/* c\n */", "b", ";"}},
+		{"// c\na", ScanComments, []string{"// c", "a", ";"}},
+		{"a = 1 // c", ScanComments, []string{"a", "=", "1", ";", "// c"}},
+	}
+	for _, test := range tests {
+		tokens, err := scan(t, test.src, test.mode)
+		if err != nil {
+			t.Errorf("%q: %v", test.src, err)
+			continue
+		}
+		if got := literals(tokens); !slices.Equal(got, test.literals) {
+			t.Errorf("%q: got %q, want %q", test.src, got, test.literals)
+		}
+	}
+}
+
+// kindTest is the source of the single token and its kind.
+type kindTest struct {
+	src  string
+	kind token.Kind
+}
+
+// testKinds checks that each source is scanned as the single token of the kind.
+func testKinds(t *testing.T, tests []kindTest) {
+	t.Helper()
+	for _, test := range tests {
+		tokens, err := scan(t, test.src, 0)
+		if err != nil {
+			t.Errorf("%s: %v", test.src, err)
+			continue
+		}
+		if got := tokens[0]; got.Literal != test.src || got.Kind != test.kind {
+			t.Errorf("%s: got %s %q, want %s", test.src, got.Kind, got.Literal, test.kind)
+		}
+	}
+}
+
+// errorTest is the invalid source and the part of the error message.
+type errorTest struct {
+	src string
+	err string
+}
+
+// testErrors checks that scanning each source fails with the error.
+func testErrors(t *testing.T, tests []errorTest) {
+	t.Helper()
+	for _, test := range tests {
+		_, err := scan(t, test.src, 0)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: got error %v, want %q", test.src, err, test.err)
+		}
+	}
+}
+
+func TestScanIdentifiers(t *testing.T) {
+	testKinds(t, []kindTest{
+		{"héllo", token.Identifier},
+		{"世界", token.Identifier},
+		{"x_1", token.Identifier},
+		{"for1", token.Identifier},
+		{"_", token.Identifier},
+	})
+	testErrors(t, []errorTest{
+		{"x := \xff", "invalid UTF-8"},
+	})
+}
+
+func TestScanPositions(t *testing.T) {
+	tokens, err := scan(t, "\uFEFFhé := 'ü'\nx", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		literal  string
+		pos, end string
+	}{
+		{"hé", "1:1", "1:3"},
+		{":=", "1:4", "1:6"},
+		{"'ü'", "1:7", "1:10"},
+		{";", "1:10", "1:10"},
+		{"x", "2:1", "2:2"},
+	}
+	for i, test := range tests {
+		got := tokens[i]
+		if got.Literal != test.literal || got.Position.String() != test.pos || got.End.String() != test.end {
+			t.Errorf("token %d: got %q at %v-%v, want %q at %s-%s", i, got.Literal, got.Position, got.End, test.literal, test.pos, test.end)
+		}
+	}
+}
+
+func TestScanStrings(t *testing.T) {
+	testKinds(t, []kindTest{
+		{`"hello"`, token.String},
+		{`"a\tb\n\\\""`, token.String},
+		{`"\x41é\U0001F600\101"`, token.String},
+		{`"héllo, 世界"`, token.String},
+		{"`raw \\n string`", token.RawString},
+		{"`multi\nline`", token.RawString},
+	})
+	testErrors(t, []errorTest{
+		{`"\q"`, "unknown escape sequence"},
+		{`"abc`, "not terminated"},
+		{"`abc", "not terminated"},
+	})
+}
+
+func TestScanRunes(t *testing.T) {
+	testKinds(t, []kindTest{
+		{"'a'", token.Char},
+		{"'é'", token.Char},
+		{`'\n'`, token.Char},
+		{`'\''`, token.Char},
+		{`'\x41'`, token.Char},
+	})
+	testErrors(t, []errorTest{
+		{"'ab'", "more than one character in rune literal"},
+		{"''", "empty rune literal"},
+	})
+}
+
+func TestScanNumbers(t *testing.T) {
+	testKinds(t, []kindTest{
+		{"42", token.Integer},
+		{"1_000_000", token.Integer},
+		{"0x1F", token.Integer},
+		{"0X_ff", token.Integer},
+		{"0b1010", token.Integer},
+		{"0o17", token.Integer},
+		{"017", token.Integer},
+		{"1.5", token.Float},
+		{".5", token.Float},
+		{"1.", token.Float},
+		{"1e10", token.Float},
+		{"1.5E-3", token.Float},
+		{"0x1p-2", token.Float},
+		{"0x1.8P+1", token.Float},
+	})
+	testErrors(t, []errorTest{
+		{"1__0", "'_' must separate successive digits"},
+		{"1_", "'_' must separate successive digits"},
+		{"0x", "hexadecimal literal has no digits"},
+	})
+}
+
+func TestScanSemicolons(t *testing.T) {
+	tests := []struct {
+		src      string
+		literals []string
+	}{
+		{"x\ny", []string{"x", ";", "y", ";"}},
+		{"x = 1\n", []string{"x", "=", "1", ";"}},
+		{"f(\na,\n)\n", []string{"f", "(", "a", ",", ")", ";"}},
+		{"x +\ny", []string{"x", "+", "y", ";"}},
+		{"x++\ny--\n", []string{"x", "++", ";", "y", "--", ";"}},
+		{"return\nbreak\ncontinue\nfallthrough\n", []string{"return", ";", "break", ";", "continue", ";", "fallthrough", ";"}},
+		{"if x {\n}\n", []string{"if", "x", "{", "}", ";"}},
+		{"xs[i]\n", []string{"xs", "[", "i", "]", ";"}},
+		{"s := `a\nb`\n", []string{"s", ":=", "`a\nb`", ";"}},
+		{"x\n\n\ny", []string{"x", ";", "y", ";"}},
+	}
+	for _, test := range tests {
+		tokens, err := scan(t, test.src, 0)
+		if err != nil {
+			t.Errorf("%q: %v", test.src, err)
+			continue
+		}
+		if got := literals(tokens); !slices.Equal(got, test.literals) {
+			t.Errorf("%q: got %q, want %q", test.src, got, test.literals)
+		}
+	}
+}
@@ -30,61 +30,221 @@ func tokenizeNumber(c context) (*token.Token, error) {
 	if err != nil {
 		return nil, err
 	}
-	if !unicode.IsNumber(r) {
+	start, line, column := c.position().Position, c.position().Line, c.position().Column
+	if r == '.' {
+		next, err := c.slice(start+1, start+2)
+		if err != nil || !isDecimal(rune(next[0])) {
+			return nil, errNoMatch
+		}
+	} else if !isDecimal(r) {
 		return nil, errNoMatch
 	}
-	start := c.position().Position
 	c.debug("tokenizing number")
-	for {
-		if err := c.advance(1); err != nil {
-			break
+
+	kind := token.Integer
+	base, prefix := 10, rune(0)
+	digsep := 0 // bit 0: digit present, bit 1: '_' present
+	invalid := -1
+
+	// integer part
+	if r != '.' {
+		if r == '0' {
+			c.advance(1)
+			r, _ = c.current()
+			switch lower(r) {
+			case 'x':
+				c.advance(1)
+				base, prefix = 16, 'x'
+			case 'o':
+				c.advance(1)
+				base, prefix = 8, 'o'
+			case 'b':
+				c.advance(1)
+				base, prefix = 2, 'b'
+			default:
+				base, prefix = 8, '0'
+				digsep = 1 // leading 0
+			}
+		}
+		digsep |= scanDigits(c, base, &invalid)
+		r, _ = c.current()
+		if r == '.' {
+			if prefix == 'o' || prefix == 'b' {
+				return nil, fmt.Errorf("invalid radix point in %s at %d:%d", literalName(prefix), line, column)
+			}
 		}
+	}
 
-		r, err = c.current()
-		if err != nil {
-			break
+	// fractional part
+	if r == '.' {
+		kind = token.Float
+		c.advance(1)
+		digsep |= scanDigits(c, base, &invalid)
+	}
+
+	if digsep&1 == 0 {
+		return nil, fmt.Errorf("%s has no digits at %d:%d", literalName(prefix), line, column)
+	}
+
+	// exponent
+	r, _ = c.current()
+	if e := lower(r); e == 'e' || e == 'p' {
+		switch {
+		case e == 'e' && prefix != 0 && prefix != '0':
+			return nil, fmt.Errorf("%q exponent requires decimal mantissa at %d:%d", r, line, column)
+		case e == 'p' && prefix != 'x':
+			return nil, fmt.Errorf("%q exponent requires hexadecimal mantissa at %d:%d", r, line, column)
 		}
+		c.advance(1)
+		kind = token.Float
+		r, _ = c.current()
+		if r == '+' || r == '-' {
+			c.advance(1)
+		}
+		ds := scanDigits(c, 10, nil)
+		digsep |= ds
+		if ds&1 == 0 {
+			return nil, fmt.Errorf("exponent has no digits at %d:%d", line, column)
+		}
+	} else if prefix == 'x' && kind == token.Float {
+		return nil, fmt.Errorf("hexadecimal mantissa requires a 'p' exponent at %d:%d", line, column)
+	}
+
+	str, err := c.slice(start, c.position().Position)
+	if err != nil {
+		return nil, err
+	}
+	if kind == token.Integer && invalid >= 0 {
+		return nil, fmt.Errorf("invalid digit %q in %s at %d:%d", str[invalid-start], literalName(prefix), line, column+invalid-start)
+	}
+	if digsep&2 != 0 {
+		if i := invalidSeparator(str); i >= 0 {
+			return nil, fmt.Errorf("'_' must separate successive digits at %d:%d", line, column+i)
+		}
+	}
+	return c.new(str, kind), nil
+}
 
-		if !unicode.IsNumber(r) {
+// scanDigits consumes the digits of the given base, including '_' separators.
+// For bases up to 10, all decimal digits are consumed, and the offset
+// of the first one that doesn't belong to the base is written to invalid.
+// The result has bit 0 set if a digit was met, and bit 1 set if a separator was met.
+func scanDigits(c context, base int, invalid *int) int {
+	digsep := 0
+	for {
+		r, err := c.current()
+		if err != nil {
 			break
 		}
+		ds := 1
+		switch {
+		case r == '_':
+			ds = 2
+		case base <= 10 && isDecimal(r):
+			if r >= rune('0'+base) && invalid != nil && *invalid < 0 {
+				*invalid = c.position().Position
+			}
+		case base == 16 && isHex(r):
+		default:
+			return digsep
+		}
+		digsep |= ds
+		c.advance(1)
 	}
-	if r == '.' {
-		c.debug("detected dot, consuming fractional part")
-		if err := c.advance(1); err != nil {
-			return nil, fmt.Errorf("expected a number after dot at %d", c.position().Line)
+	return digsep
+}
+
+// invalidSeparator returns the index of the first invalid '_' separator in x,
+// or -1 if all of them separate digits.
+func invalidSeparator(x string) int {
+	x1 := ' ' // prefix char, we only care if it's 'x'
+	d := '.'  // digit, one of '_', '0' (a digit), or '.' (anything else)
+	i := 0
+
+	// a prefix counts as a digit
+	if len(x) >= 2 && x[0] == '0' {
+		x1 = lower(rune(x[1]))
+		if x1 == 'x' || x1 == 'o' || x1 == 'b' {
+			d = '0'
+			i = 2
 		}
+	}
 
-		r, _ = c.current()
-		if !unicode.IsNumber(r) {
-			return nil, fmt.Errorf("expected a number after dot at %d", c.position().Line)
+	// mantissa and exponent
+	for ; i < len(x); i++ {
+		p := d // previous digit
+		d = rune(x[i])
+		switch {
+		case d == '_':
+			if p != '0' {
+				return i
+			}
+		case isDecimal(d) || x1 == 'x' && isHex(d):
+			d = '0'
+		default:
+			if p == '_' {
+				return i - 1
+			}
+			d = '.'
 		}
+	}
+	if d == '_' {
+		return len(x) - 1
+	}
+	return -1
+}
 
+func literalName(prefix rune) string {
+	switch prefix {
+	case 'x':
+		return "hexadecimal literal"
+	case 'o', '0':
+		return "octal literal"
+	case 'b':
+		return "binary literal"
+	}
+	return "decimal literal"
+}
+
+func lower(r rune) rune     { return ('a' - 'A') | r }
+func isDecimal(r rune) bool { return '0' <= r && r <= '9' }
+func isHex(r rune) bool     { return '0' <= r && r <= '9' || 'a' <= lower(r) && lower(r) <= 'f' }
+
+func tokenizeComment(c context) (*token.Token, error) {
+	start := c.position().Position
+	str, err := c.slice(start, start+2)
+	if err != nil || (str != "//" && str != "/*") {
+		return nil, errNoMatch
+	}
+	line, column := c.position().Line, c.position().Column
+	c.debug("tokenizing comment")
+	c.advance(2)
+	if str == "//" {
 		for {
-			if err := c.advance(1); err != nil {
+			r, err := c.current()
+			if err != nil || r == '\n' {
 				break
 			}
-
-			r, err = c.current()
-			if err != nil {
-				break
+			c.advance(1)
+		}
+	} else {
+		for {
+			if c.eof() {
+				return nil, fmt.Errorf("comment not terminated at %d:%d", line, column)
 			}
-
-			if !unicode.IsNumber(r) {
+			end, err := c.slice(c.position().Position, c.position().Position+2)
+			if err == nil && end == "*/" {
+				c.advance(2)
 				break
 			}
+			c.advance(1)
 		}
-		str, err := c.slice(start, c.position().Position)
-		if err != nil {
-			return nil, err
-		}
-		return c.new(str, token.Float), nil
 	}
-	str, err := c.slice(start, c.position().Position)
+	str, err = c.slice(start, c.position().Position)
 	if err != nil {
 		return nil, err
 	}
-	return c.new(str, token.Integer), nil
+	return c.new(str, token.Comment), nil
 }
 
 func tokenizeKeyword(c context) (*token.Token, error) {
@@ -104,33 +264,11 @@ func tokenizeType(c context) (*token.Token, error) {
 }
 
 func tokenizeSeparator(c context) (*token.Token, error) {
-	start := c.position().Position
-	multiChars := []string{"...", "//"}
-	for _, sep := range multiChars {
-		end := start + len(sep)
-		str, err := c.slice(start, end)
-		if err != nil {
-			continue
-		}
-		if slices.Contains(token.Separators, str) {
-			c.advance(len(sep))
-			return c.new(str, token.Separator), nil
-		}
-	}
-	_, err := c.current()
+	str, err := selectSymbolAndCheck(c, token.Separators)
 	if err != nil {
 		return nil, err
 	}
-	str, err := c.slice(start, start+1)
-	if err != nil {
-		return nil, err
-	}
-
-	if slices.Contains(token.Separators, str) {
-		c.advance(1)
-		return c.new(str, token.Separator), nil
-	}
-	return nil, errNoMatch
+	return c.new(str, token.Separator), nil
 }
 
 func tokenizeIdentifier(c context) (*token.Token, error) {
@@ -159,45 +297,206 @@ func tokenizeIdentifier(c context) (*token.Token, error) {
 }
 
 func tokenizeString(c context) (*token.Token, error) {
-	r, _ := c.current()
-	if r != '"' && r != '`' {
+	quote, _ := c.current()
+	if quote != '"' && quote != '`' {
 		return nil, errNoMatch
 	}
-
-	multiline := false
-	if r == '`' {
-		multiline = true
-	}
-
+	start, line, column := c.position().Position, c.position().Line, c.position().Column
+	c.debug("tokenizing string")
 	c.advance(1)
-	start, line := c.position().Position, c.position().Line
 	for {
-		r, _ := c.current()
-		if c.eof() || (r == '"' || r == '`') {
+		r, err := c.current()
+		if err != nil {
+			return nil, fmt.Errorf("string literal not terminated at %d:%d", line, column)
+		}
+		if r == quote {
+			c.advance(1)
 			break
 		}
-		c.advance(1)
-		if c.position().Line != line && !multiline {
-			return nil, fmt.Errorf("a string with '\"' quote doesn't allow multiline")
+		if quote == '"' {
+			if r == '\n' {
+				return nil, fmt.Errorf("a string with '\"' quote doesn't allow multiline at %d:%d", line, column)
+			}
+			if r == '\\' {
+				if err := scanEscape(c, quote); err != nil {
+					return nil, err
+				}
+				continue
+			}
 		}
+		c.advance(1)
 	}
-	c.advance(1)
-	str, err := c.slice(start, c.position().Position-1)
+	str, err := c.slice(start, c.position().Position)
 	if err != nil {
 		return nil, err
 	}
+	if quote == '`' {
+		return c.new(str, token.RawString), nil
+	}
 	return c.new(str, token.String), nil
 }
 
-func tokenizeBinaryOperator(c context) (*token.Token, error) {
+func tokenizeChar(c context) (*token.Token, error) {
 	r, _ := c.current()
+	if r != '\'' {
+		return nil, errNoMatch
+	}
+	start, line, column := c.position().Position, c.position().Line, c.position().Column
+	c.debug("tokenizing rune")
+	c.advance(1)
+	n := 0
+	for {
+		r, err := c.current()
+		if err != nil || r == '\n' {
+			return nil, fmt.Errorf("rune literal not terminated at %d:%d", line, column)
+		}
+		if r == '\'' {
+			c.advance(1)
+			break
+		}
+		n++
+		if r == '\\' {
+			if err := scanEscape(c, '\''); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		c.advance(1)
+	}
 	switch {
-	case slices.Contains(token.BinaryOperators, string(r)):
+	case n == 0:
+		return nil, fmt.Errorf("empty rune literal at %d:%d", line, column)
+	case n > 1:
+		return nil, fmt.Errorf("more than one character in rune literal at %d:%d", line, column)
+	}
+	str, err := c.slice(start, c.position().Position)
+	if err != nil {
+		return nil, err
+	}
+	return c.new(str, token.Char), nil
+}
+
+// scanEscape consumes an escape sequence, starting at the backslash,
+// and reports whether it's valid within a literal quoted with quote.
+func scanEscape(c context, quote rune) error {
+	line, column := c.position().Line, c.position().Column
+	c.advance(1)
+	r, err := c.current()
+	if err != nil {
+		return fmt.Errorf("escape sequence not terminated at %d:%d", line, column)
+	}
+
+	var n int
+	var base, max uint32
+	switch r {
+	case 'a', 'b', 'f', 'n', 'r', 't', 'v', '\\', quote:
+		c.advance(1)
+		return nil
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		n, base, max = 3, 8, 255
+	case 'x':
+		c.advance(1)
+		n, base, max = 2, 16, 255
+	case 'u':
 		c.advance(1)
-		return c.new(string(r), token.BinaryOperator), nil
+		n, base, max = 4, 16, unicode.MaxRune
+	case 'U':
+		c.advance(1)
+		n, base, max = 8, 16, unicode.MaxRune
 	default:
-		return nil, errNoMatch
+		return fmt.Errorf("unknown escape sequence %q at %d:%d", "\\"+string(r), line, column)
 	}
+
+	var x uint32
+	for ; n > 0; n-- {
+		r, err := c.current()
+		if err != nil {
+			return fmt.Errorf("escape sequence not terminated at %d:%d", line, column)
+		}
+		d := uint32(digitValue(r))
+		if d >= base {
+			return fmt.Errorf("illegal character %q in escape sequence at %d:%d", r, line, column)
+		}
+		x = x*base + d
+		c.advance(1)
+	}
+	if x > max || 0xD800 <= x && x < 0xE000 {
+		return fmt.Errorf("escape sequence is invalid Unicode code point at %d:%d", line, column)
+	}
+	return nil
+}
+
+// digitValue returns the value of r as a hexadecimal digit,
+// or 16 if r is not a digit at all.
+func digitValue(r rune) int {
+	switch {
+	case '0' <= r && r <= '9':
+		return int(r - '0')
+	case 'a' <= r && r <= 'f':
+		return int(r - 'a' + 10)
+	case 'A' <= r && r <= 'F':
+		return int(r - 'A' + 10)
+	}
+	return 16
+}
+
+func tokenizeBinaryOperator(c context) (*token.Token, error) {
+	str, err := selectSymbolAndCheck(c, token.BinaryOperators)
+	if err != nil {
+		return nil, err
+	}
+	return c.new(str, token.BinaryOperator), nil
+}
+
+func tokenizeAssignOperator(c context) (*token.Token, error) {
+	str, err := selectSymbolAndCheck(c, token.AssignOperators)
+	if err != nil {
+		return nil, err
+	}
+	return c.new(str, token.AssignOperator), nil
+}
+
+func tokenizeIncDecOperator(c context) (*token.Token, error) {
+	str, err := selectSymbolAndCheck(c, token.IncDecOperators)
+	if err != nil {
+		return nil, err
+	}
+	return c.new(str, token.IncDecOperator), nil
+}
+
+func tokenizeUnaryOperator(c context) (*token.Token, error) {
+	str, err := selectSymbolAndCheck(c, token.UnaryOperators)
+	if err != nil {
+		return nil, err
+	}
+	return c.new(str, token.UnaryOperator), nil
+}
+
+// selectSymbolAndCheck selects the longest symbol (a separator or an operator)
+// at the current position, and checks if it belongs to the collection.
+// Selecting the longest symbol first makes sure that, for example,
+// "<=" is never tokenized as "<" followed by "=".
+func selectSymbolAndCheck(c context, collection token.Collection) (string, error) {
+	start := c.position().Position
+	symbols := slices.Concat(
+		token.Separators,
+		token.BinaryOperators,
+		token.UnaryOperators,
+		token.AssignOperators,
+		token.IncDecOperators,
+	)
+	for n := 3; n > 0; n-- {
+		str, err := c.slice(start, start+n)
+		if err != nil || !slices.Contains(symbols, str) {
+			continue
+		}
+		if !slices.Contains(collection, str) {
+			return "", errNoMatch
+		}
+		c.advance(n)
+		return str, nil
+	}
+	return "", errNoMatch
 }
 
 func selectWordAndCheck(c context, collection token.Collection) (string, error) {
@@ -207,7 +506,7 @@ func selectWordAndCheck(c context, collection token.Collection) (string, error)
 	start := c.position().Position
 	for {
 		r, _ := c.current()
-		if c.eof() || !unicode.IsLetter(r) {
+		if c.eof() || !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') {
 			break
 		}
 		c.advance(1)
@@ -1,7 +1,9 @@
 package token
 
 import (
+	"fmt"
 	"slices"
+	"strconv"
 	"unicode"
 )
 
@@ -21,6 +23,9 @@ type Collection []string
 
 // Position represents the token position.
 // It should be used as a pointer to provide correct position information.
+//
+// Line and Column are 1-based, and Column is counted in runes,
+// while Position is the 0-based byte offset in the source.
 type Position struct {
 	Line     int `json:"line"`
 	Column   int `json:"column"`
@@ -29,15 +34,17 @@ type Position struct {
 
 // Token is a stream of characters,
 // with the literal, kind and position.
+// End is the position right after the last character of the token.
 type Token struct {
 	Literal  string    `json:"literal"`
 	Kind     Kind      `json:"kind"`
 	Position *Position `json:"position"`
+	End      *Position `json:"end"`
 }
 
 // NewTokens returns a pointer to Token struct..
 func NewToken(literal string, kind Kind, position *Position) *Token {
-	return &Token{literal, kind, position}
+	return &Token{Literal: literal, Kind: kind, Position: position}
 }
 
 // NewPosition returns a pointer to Position struct.
@@ -45,6 +52,11 @@ func NewPosition(line int, column int, position int) *Position {
 	return &Position{line, column, position}
 }
 
+// String returns the position in "line:column" form.
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
 // A token kind.
 var (
 	Identifier     Kind = "identifier"
@@ -54,7 +66,13 @@ var (
 	Keyword        Kind = "keyword"
 	Separator      Kind = "separator"
 	String         Kind = "string"
+	RawString      Kind = "raw-string"
+	Char           Kind = "char"
 	BinaryOperator Kind = "binary-operator"
+	UnaryOperator  Kind = "unary-operator"
+	AssignOperator Kind = "assign-operator"
+	IncDecOperator Kind = "inc-dec-operator"
+	Comment        Kind = "comment"
 	Eof            Kind = "eof"
 	Illegal        Kind = "illegal"
 )
@@ -79,6 +97,14 @@ var (
 		"range",
 		"return",
 		"switch",
+		"fallthrough",
+		"goto",
+		"chan",
+		"struct",
+		"interface",
+		"defer",
+		"go",
+		"select",
 	}
 
 	Separators Collection = Collection{
@@ -93,9 +119,9 @@ var (
 		"}",
 		"...",
 		".",
-		"//",
 		"=",
 		":=",
+		":",
 	}
 
 	Types Collection = Collection{
@@ -111,6 +137,54 @@ var (
 		"-",
 		"/",
 		"*",
+		"%",
+		"&",
+		"|",
+		"^",
+		"&^",
+		"<<",
+		">>",
+		"==",
+		"!=",
+		"<",
+		"<=",
+		">",
+		">=",
+		"&&",
+		"||",
+	}
+
+	// UnaryOperators contains all unary operators, some of which are binary too.
+	// Only the ones that are never binary are tokenized as UnaryOperator.
+	// "~" is only allowed in the type sets of interfaces.
+	UnaryOperators Collection = Collection{
+		"-",
+		"+",
+		"!",
+		"^",
+		"&",
+		"*",
+		"<-",
+		"~",
+	}
+
+	AssignOperators Collection = Collection{
+		"+=",
+		"-=",
+		"*=",
+		"/=",
+		"%=",
+		"<<=",
+		">>=",
+		"&=",
+		"|=",
+		"^=",
+		"&^=",
+	}
+
+	IncDecOperators Collection = Collection{
+		"++",
+		"--",
 	}
 )
 
@@ -140,3 +214,16 @@ func IsIdentifier(s string) bool {
 	}
 	return true
 }
+
+// Unquote returns the value of a quoted string or rune literal,
+// as it's written in the code, following the Go rules:
+//
+//   - interpreted strings ("...") and runes ('...') have their escape sequences decoded;
+//   - raw strings (`...`) are taken as is, except carriage returns are discarded.
+func Unquote(literal string) (string, error) {
+	s, err := strconv.Unquote(literal)
+	if err != nil {
+		return "", fmt.Errorf("token: invalid quoted literal %s", literal)
+	}
+	return s, nil
+}